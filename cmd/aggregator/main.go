@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"pricenotification/internal/aggregation"
+	"pricenotification/internal/models"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Kafka broker details
+const kafkaBroker = "localhost:9094"
+
+// Topic carrying consolidated cross-venue prices
+const consolidatedTopic = "price.consolidated"
+
+func main() {
+	methodName := flag.String("method", "vwap", "Consolidation method: vwap, median or mid")
+	window := flag.Duration("window", 10*time.Second, "Sliding window of trades used for each consolidated price")
+	interval := flag.Duration("interval", time.Second, "How often consolidated prices are published")
+	flag.Parse()
+
+	method, err := aggregation.ParseMethod(*methodName)
+	if err != nil {
+		log.Fatal("❌ Invalid aggregation configuration:", err)
+	}
+
+	// Create Kafka consumer
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": kafkaBroker,
+		"group.id":          "price-aggregation-group",
+		"auto.offset.reset": "latest",
+	})
+	if err != nil {
+		log.Fatal("❌ Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": kafkaBroker})
+	if err != nil {
+		log.Fatal("❌ Failed to create Kafka producer:", err)
+	}
+	defer producer.Close()
+
+	// Subscribe to raw per-exchange price updates
+	err = consumer.Subscribe("price.updates", nil)
+	if err != nil {
+		log.Fatal("❌ Failed to subscribe to Kafka topic:", err)
+	}
+
+	aggregator := aggregation.New(method, *window)
+
+	// Publish consolidated prices on a fixed cadence rather than per trade
+	go func() {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			for _, update := range aggregator.Consolidate(now) {
+				publishConsolidated(producer, update)
+			}
+		}
+	}()
+
+	fmt.Printf("✅ Aggregating price updates (method=%s, window=%s)...\n", method, *window)
+
+	// Consume messages
+	for {
+		msg, err := consumer.ReadMessage(-1)
+		if err != nil {
+			fmt.Println("Kafka consumer error:", err)
+			continue
+		}
+
+		var priceUpdate models.PriceUpdate
+		if err := json.Unmarshal(msg.Value, &priceUpdate); err != nil {
+			log.Println("❌ Error parsing price update:", err)
+			continue
+		}
+
+		aggregator.Add(priceUpdate, time.Now())
+	}
+}
+
+// publishConsolidated sends a consolidated price keyed by symbol
+func publishConsolidated(producer *kafka.Producer, update models.PriceUpdate) {
+	value, err := json.Marshal(update)
+	if err != nil {
+		log.Println("Error marshaling JSON:", err)
+		return
+	}
+
+	topic := consolidatedTopic
+	err = producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(update.Symbol),
+		Value:          value,
+	}, nil)
+	if err != nil {
+		log.Println("Error producing Kafka message:", err)
+		return
+	}
+
+	fmt.Printf("📊 Consolidated %s: %.2f\n", update.Symbol, update.Price)
+}
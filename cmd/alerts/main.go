@@ -70,6 +70,16 @@ func main() {
 		}
 	})
 
+	// Handler for per-user resources: /users/{id}/notifications
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UsersHandler(w, r, *instance)
+	})
+
+	// Handler for price queries: /prices/{symbol}/latest, /prices/{symbol}/history
+	mux.HandleFunc("/prices/", func(w http.ResponseWriter, r *http.Request) {
+		handlers.PricesHandler(w, r, *instance)
+	})
+
 	logger.Log.Info("Alerts service starting on", zap.String("port", *port))
 	log.Fatal(http.ListenAndServe(":"+*port, mux))
 }
\ No newline at end of file
@@ -3,27 +3,37 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"pricenotification/internal/alertindex"
+	"pricenotification/internal/alertstate"
 	"pricenotification/internal/cache"
+	"pricenotification/internal/candles"
 	"pricenotification/internal/database"
-	"pricenotification/internal/handlers"
+	"pricenotification/internal/expr"
+	"pricenotification/internal/indicators"
 	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+	"pricenotification/internal/notify"
+	"pricenotification/internal/pricewindow"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/uuid"
 )
 
-// Price update structure (from Kafka)
-type PriceUpdate struct {
-	Exchange  string  `json:"exchange"`
-	Symbol    string  `json:"symbol"`
-	Price     float64 `json:"price"`
-	Timestamp string  `json:"timestamp"`
-}
+// cooldown is the minimum time between two firings of the same alert,
+// unless the alert sets its own
+var cooldown time.Duration
 
 func main() {
+	flag.DurationVar(&cooldown, "cooldown", 30*time.Second, "Default minimum time between two firings of the same alert")
+	flag.Parse()
+
 	logger.InitLogger()
 	
 	// Initialize Redis - important addition
@@ -35,6 +45,18 @@ func main() {
 		log.Fatal("❌ Database connection failed:", err)
 	}
 
+	// Load every alert into memory before consuming prices
+	alerts, err := database.GetAllAlerts(context.Background())
+	if err != nil {
+		log.Fatal("❌ Failed to load alerts:", err)
+	}
+	alertIndex.Replace(alerts)
+	fmt.Printf("✅ Indexed %d active alerts\n", alertIndex.Len())
+
+	go watchAlertChanges(context.Background())
+	go sweepAlertStatuses(context.Background())
+	go expireSustainedAlerts(context.Background())
+
 	// Create Kafka consumer
 	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers": "localhost:9094",
@@ -46,8 +68,9 @@ func main() {
 	}
 	defer consumer.Close()
 
-	// Subscribe to price updates
-	err = consumer.Subscribe("price.updates", nil)
+	// Subscribe to raw per-exchange and consolidated price updates; each
+	// alert watches exactly one of these streams
+	err = consumer.SubscribeTopics([]string{"price.updates", "price.consolidated"}, nil)
 	if err != nil {
 		log.Fatal("❌ Failed to subscribe to Kafka topic:", err)
 	}
@@ -63,7 +86,7 @@ func main() {
 		}
 
 		// Parse price update message
-		var priceUpdate PriceUpdate
+		var priceUpdate models.PriceUpdate
 		if err := json.Unmarshal(msg.Value, &priceUpdate); err != nil {
 			log.Println("❌ Error parsing price update:", err)
 			continue
@@ -76,65 +99,745 @@ func main() {
 	}
 }
 
-// Map to track last triggered alerts (symbol → last notified timestamp)
-var lastAlertTime = make(map[string]time.Time)
+// alertIndex holds every alert in memory, kept current from alert change
+// notifications
+var alertIndex = alertindex.New()
 
-// Cooldown duration before re-triggering the same alert
-const cooldown = 30 * time.Second // Adjust as needed
+// alertResyncInterval bounds how long a missed alert change goes unnoticed
+const alertResyncInterval = time.Minute
 
-func processPriceUpdate(priceUpdate PriceUpdate) {
-	ctx := context.Background()
-	alerts, err := database.GetAlertsBySymbol(ctx, priceUpdate.Symbol)
+// watchAlertChanges applies alert writes to the index as they are notified,
+// and reloads the whole index periodically and after listener reconnects
+func watchAlertChanges(ctx context.Context) {
+	changes := make(chan *models.AlertChange, 100)
+	go func() {
+		for ctx.Err() == nil {
+			err := database.ListenAlertChanges(ctx, func(change *models.AlertChange) {
+				changes <- change
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Println("❌ Alert change listener failed:", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(alertResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-changes:
+			if change == nil {
+				resyncAlerts(ctx)
+				continue
+			}
+			applyAlertChange(ctx, change)
+		case <-ticker.C:
+			resyncAlerts(ctx)
+		}
+	}
+}
+
+// applyAlertChange updates the index for one alert write
+func applyAlertChange(ctx context.Context, change *models.AlertChange) {
+	if change.Op == models.AlertDeleted {
+		alertIndex.Remove(change.AlertID)
+		conditions.Delete(change.AlertID)
+		return
+	}
+
+	alert, err := database.GetAlertByID(ctx, change.AlertID)
 	if err != nil {
-		log.Println("❌ Failed to fetch alerts:", err)
+		// Deleted again in the meantime, or a database error: the
+		// periodic resync settles it either way
+		log.Println("❌ Failed to load changed alert:", err)
 		return
 	}
+	alertIndex.Set(alert)
 
-	for _, alert := range alerts {
-		triggered := false
-		alertKey := fmt.Sprintf("%s_%s", alert.UserID, alert.Symbol) // Unique key per user-symbol alert
+	// Alerts that are no longer active leave the index
+	if _, ok := alertIndex.Get(alert.ID); !ok {
+		conditions.Delete(alert.ID)
+	}
+}
 
-		// Enforce cooldown: Don't send the same alert too frequently
-		if lastTime, exists := lastAlertTime[alertKey]; exists {
-			if time.Since(lastTime) < cooldown {
-				fmt.Printf("⏳ Alert suppressed for %s (cooldown active)\n", alertKey)
+// statusSweepInterval is how late an alert may expire or wake from a snooze
+const statusSweepInterval = 5 * time.Second
+
+// sweepAlertStatuses moves alerts past their expiry to expired and resumes
+// snoozed alerts whose snooze ended. Every replica runs it; the updates are
+// idempotent. Until it runs, processPriceUpdate skips expired alerts itself.
+func sweepAlertStatuses(ctx context.Context) {
+	ticker := time.NewTicker(statusSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := database.ExpireAlerts(ctx, now)
+			if err != nil {
+				log.Println("❌ Failed to expire alerts:", err)
+			} else if expired > 0 {
+				fmt.Printf("⌛ Expired %d alerts\n", expired)
+			}
+
+			resumed, err := database.ResumeSnoozedAlerts(ctx, now)
+			if err != nil {
+				log.Println("❌ Failed to resume snoozed alerts:", err)
+			} else if resumed > 0 {
+				fmt.Printf("⏰ Resumed %d snoozed alerts\n", resumed)
+			}
+		}
+	}
+}
+
+// resyncAlerts reloads the index from the alerts table
+func resyncAlerts(ctx context.Context) {
+	alerts, err := database.GetAllAlerts(ctx)
+	if err != nil {
+		log.Println("❌ Failed to reload alerts:", err)
+		return
+	}
+	alertIndex.Replace(alerts)
+	pruneConditions()
+}
+
+// pruneConditions drops the compiled conditions of alerts no longer in the
+// index, e.g. deleted while the change listener was reconnecting
+func pruneConditions() {
+	conditions.Range(func(key, _ any) bool {
+		if _, ok := alertIndex.Get(key.(string)); !ok {
+			conditions.Delete(key)
+		}
+		return true
+	})
+}
+
+func processPriceUpdate(priceUpdate models.PriceUpdate) {
+	ctx := context.Background()
+	now := time.Now()
+
+	var window *pricewindow.Window
+	if alertIndex.TracksMoves(priceUpdate.Symbol, priceUpdate.Exchange) {
+		window = priceWindow(ctx, priceUpdate.Symbol, priceUpdate.Exchange)
+		window.Add(priceUpdate.Price, priceUpdate.Size, now)
+	}
+
+	// Every series must see every trade so that its candles close on time
+	var series map[string]indicatorUpdate
+	for _, interval := range alertIndex.Intervals(priceUpdate.Symbol, priceUpdate.Exchange) {
+		if series == nil {
+			series = make(map[string]indicatorUpdate)
+		}
+		s := indicatorSeries(ctx, priceUpdate.Symbol, priceUpdate.Exchange, interval)
+		closed := s.Add(priceUpdate.Price, candles.TradeTime(priceUpdate, now))
+		series[interval] = indicatorUpdate{series: s, closed: closed}
+	}
+
+	if alertIndex.TracksLegs(priceUpdate.Symbol, priceUpdate.Exchange) {
+		err := alertstate.RecordLegPrice(ctx, priceUpdate.Symbol, priceUpdate.Exchange, priceUpdate.Price, now)
+		if err != nil {
+			log.Println("❌ Failed to record leg price:", err)
+		}
+	}
+
+	for _, alert := range alertIndex.Candidates(priceUpdate) {
+		if alert.ExpiresAt != nil && !now.Before(*alert.ExpiresAt) {
+			continue
+		}
+
+		if alert.Type == models.AlertTypeCondition || alert.Type == models.AlertTypeIndicator {
+			indicator, ok := series[alertindex.ConditionInterval(alert)]
+			if window == nil || !ok {
 				continue
 			}
+			env := conditionEnv{update: priceUpdate, window: window, series: indicator.series, live: !alert.OnClose, now: now}
+			if alert.OnClose {
+				// Evaluated once per candle, at the close of the last one
+				closePrice, found := indicator.series.Close()
+				if !indicator.closed || !found {
+					continue
+				}
+				env.update.Price = closePrice
+			}
+
+			matched, ok := evaluateCondition(alert, env)
+			if !ok {
+				continue
+			}
+			side := alertstate.SideBelow
+			if matched {
+				side = alertstate.SideAbove
+			}
+			if shouldFire(ctx, alert, alertstate.Condition, side, alertstate.SideAbove, env.update.Price, "condition") {
+				fireAlert(ctx, alert, env.update, env.update.Price, "condition")
+			}
+			continue
+		}
+
+		if alert.Type == models.AlertTypeSpread {
+			value, ok := spreadValue(ctx, alert, priceUpdate, now)
+			if !ok {
+				continue
+			}
+			checkThresholds(ctx, alert, models.PriceUpdate{
+				Exchange:  alert.Exchange,
+				Symbol:    alert.Symbol,
+				Price:     value,
+				Timestamp: priceUpdate.Timestamp,
+			})
+			continue
+		}
+
+		if alert.Type == models.AlertTypeTrailing {
+			level, ok := trailLevel(ctx, alert, priceUpdate.Price)
+			if !ok {
+				continue
+			}
+			side, firingSide := alertstate.LowerSide(priceUpdate.Price, level, alert.Hysteresis), alertstate.SideBelow
+			if alert.Direction == models.DirectionUp {
+				side, firingSide = alertstate.UpperSide(priceUpdate.Price, level, alert.Hysteresis), alertstate.SideAbove
+			}
+			if shouldFire(ctx, alert, alertstate.Trail, side, firingSide, priceUpdate.Price, "trailing") {
+				fireAlert(ctx, alert, priceUpdate, level, "trailing")
+			}
+			continue
+		}
+
+		if alert.Type == models.AlertTypeLargeTrade {
+			if isLargeTrade(alert, priceUpdate) && triggerAlert(ctx, alert, now) {
+				direction := priceUpdate.Side
+				if direction == "" {
+					direction = "trade"
+				}
+				fireAlert(ctx, alert, priceUpdate, *alert.MinSize, direction)
+			}
+			continue
+		}
+
+		if alert.Type == models.AlertTypeVolumeSpike {
+			if window == nil {
+				continue
+			}
+			ratio, volume, ok := volumeRatio(alert, window, now)
+			if !ok {
+				continue
+			}
+			// A spike re-arms once the ratio falls below multiplier - hysteresis
+			side := alertstate.UpperSide(ratio, *alert.Multiplier, alert.Hysteresis)
+			if shouldFire(ctx, alert, alertstate.Volume, side, alertstate.SideAbove, priceUpdate.Price, "volume") {
+				spike := priceUpdate
+				spike.Size = volume
+				fireAlert(ctx, alert, spike, *alert.Multiplier, "volume")
+			}
+			continue
+		}
+
+		if alert.Type != models.AlertTypeThreshold {
+			if window == nil {
+				continue
+			}
+			move, direction, ok := percentMove(alert, priceUpdate.Price, window, now)
+			if !ok {
+				continue
+			}
+			// A move re-arms once it shrinks below change_pct - hysteresis
+			side := alertstate.UpperSide(move, *alert.ChangePct, alert.Hysteresis)
+			if shouldFire(ctx, alert, alertstate.Move, side, alertstate.SideAbove, priceUpdate.Price, direction) {
+				fireAlert(ctx, alert, priceUpdate, *alert.ChangePct, direction)
+			}
+			continue
 		}
 
-		if alert.LowerThreshold != nil && priceUpdate.Price <= *alert.LowerThreshold {
-			triggered = true
-			sendSSEAlert(alert.UserID, priceUpdate.Symbol, *alert.LowerThreshold, "below")
+		checkThresholds(ctx, alert, priceUpdate)
+	}
+}
+
+// checkThresholds fires alert for the upper or lower threshold that the
+// price of priceUpdate crossed
+func checkThresholds(ctx context.Context, alert *models.Alert, priceUpdate models.PriceUpdate) {
+	if alert.LowerThreshold != nil {
+		side := alertstate.LowerSide(priceUpdate.Price, *alert.LowerThreshold, alert.Hysteresis)
+		if shouldFire(ctx, alert, alertstate.Lower, side, alertstate.SideBelow, priceUpdate.Price, "below") {
+			fireAlert(ctx, alert, priceUpdate, *alert.LowerThreshold, "below")
 		}
+	}
 
-		if alert.UpperThreshold != nil && priceUpdate.Price >= *alert.UpperThreshold {
-			triggered = true
-			sendSSEAlert(alert.UserID, priceUpdate.Symbol, *alert.UpperThreshold, "above")
+	if alert.UpperThreshold != nil {
+		side := alertstate.UpperSide(priceUpdate.Price, *alert.UpperThreshold, alert.Hysteresis)
+		if shouldFire(ctx, alert, alertstate.Upper, side, alertstate.SideAbove, priceUpdate.Price, "above") {
+			fireAlert(ctx, alert, priceUpdate, *alert.UpperThreshold, "above")
 		}
+	}
+}
 
-		if triggered {
-			// Update the last triggered time to prevent duplicates
-			lastAlertTime[alertKey] = time.Now()
+// spreadValue derives the value of a spread alert from an update of one of
+// its legs and the latest price of the other. ok is false while the other
+// leg has no price, or none within the alert's stale_seconds.
+func spreadValue(ctx context.Context, alert *models.Alert, priceUpdate models.PriceUpdate, now time.Time) (float64, bool) {
+	isFirst := priceUpdate.Symbol == alert.Symbol && priceUpdate.Exchange == alert.Exchange
+	otherSymbol, otherExchange := alert.LegSymbol, alert.LegExchange
+	if !isFirst {
+		otherSymbol, otherExchange = alert.Symbol, alert.Exchange
+	}
 
-			// TODO: Send notification (Email/SMS/WebSocket)
-			fmt.Println("📌 Notification to be sent!")
+	other, ok, err := alertstate.GetLegPrice(ctx, otherSymbol, otherExchange)
+	if err != nil {
+		log.Println("❌ Failed to read leg price:", err)
+		return 0, false
+	}
+	if !ok || now.Sub(other.At) > time.Duration(alert.StaleSeconds)*time.Second {
+		return 0, false
+	}
+
+	a, b := priceUpdate.Price, other.Price
+	if !isFirst {
+		a, b = other.Price, priceUpdate.Price
+	}
+
+	switch alert.Formula {
+	case models.FormulaRatio:
+		if b == 0 {
+			return 0, false
+		}
+		return a / b, true
+	case models.FormulaPercent:
+		if b == 0 {
+			return 0, false
 		}
+		return (a - b) / b * 100, true
+	default:
+		return a - b, true
 	}
 }
 
-// Sends alert to SSE clients
-func sendSSEAlert(userID, symbol string, threshold float64, triggered string) {
-	alert := handlers.AlertMessage{
-		UserID:    userID,
-		Symbol:    symbol,
-		Threshold: threshold,
-		Triggered: triggered,
-		Timestamp: time.Now().Format(time.RFC3339),
+// priceWindows holds the recent price range of streams with move alerts
+var priceWindows = pricewindow.NewTracker()
+
+// priceWindow returns the window of a stream. A new window is seeded from
+// the closed 1m candles of the last day so moves are measured correctly
+// right after a restart. The candle of the current minute is left out: its
+// volume already includes trades that are added to the window again.
+func priceWindow(ctx context.Context, symbol, exchange string) *pricewindow.Window {
+	window, created := priceWindows.Get(symbol, exchange)
+	if created {
+		now := time.Now().Truncate(time.Minute)
+		stored, err := database.GetCandles(ctx, symbol, exchange, "1m", now.Add(-pricewindow.MaxSpan), now)
+		if err != nil {
+			log.Println("❌ Failed to seed price window:", err)
+		}
+		window.Seed(stored)
 	}
+	return window
+}
 
-	// Debug log to confirm alert is being sent
-	fmt.Printf("🚀 Triggering SSE Alert: %+v\n", alert)
+// indicatorUpdate is a series a price update was added to
+type indicatorUpdate struct {
+	series *indicators.Series
+	closed bool // the update closed a candle
+}
 
-	// This now publishes to Redis, which will be picked up by the web server
-	handlers.BroadcastAlert(alert)
-}
\ No newline at end of file
+// indicatorTracker holds the indicators of streams with condition and
+// indicator alerts, per candle interval
+var indicatorTracker = indicators.NewTracker()
+
+// indicatorSeries returns the indicators of a stream at an interval. A new
+// series is seeded from the stored candles, enough for the longest period.
+func indicatorSeries(ctx context.Context, symbol, exchange, interval string) *indicators.Series {
+	series, created := indicatorTracker.Get(symbol, exchange, interval)
+	if created {
+		now := time.Now()
+		from := now.Add(-time.Duration(indicators.MaxPeriod+1) * candles.Intervals[interval])
+		stored, err := database.GetCandles(ctx, symbol, exchange, interval, from, now)
+		if err != nil {
+			log.Println("❌ Failed to seed indicators:", err)
+		}
+		series.Seed(stored)
+	}
+	return series
+}
+
+// compiledCondition is the program of a condition alert at one revision
+type compiledCondition struct {
+	updatedAt time.Time
+	program   *expr.Program
+}
+
+// conditions caches compiled condition programs by alert ID. Programs keep
+// the state of their crossing operators between updates.
+var conditions sync.Map // alert ID -> *compiledCondition
+
+// evaluateCondition evaluates a condition alert, compiling its expression
+// on first use and again after every change. ok is false when the
+// condition cannot be evaluated yet.
+func evaluateCondition(alert *models.Alert, env expr.Env) (matched, ok bool) {
+	cached, _ := conditions.Load(alert.ID)
+	compiled, _ := cached.(*compiledCondition)
+	if compiled == nil || !compiled.updatedAt.Equal(alert.UpdatedAt) {
+		program, err := expr.Compile(alert.Condition)
+		if err != nil {
+			// Validated when the alert was saved, so this is a bug
+			log.Printf("❌ Invalid condition on alert %s: %v\n", alert.ID, err)
+			return false, false
+		}
+		compiled = &compiledCondition{updatedAt: alert.UpdatedAt, program: program}
+		conditions.Store(alert.ID, compiled)
+	}
+
+	matched, err := compiled.program.Eval(env)
+	if err != nil {
+		return false, false
+	}
+	return matched, true
+}
+
+// conditionEnv answers condition variables from a price update, the recent
+// prices of its stream and its indicators at the alert's interval
+type conditionEnv struct {
+	update models.PriceUpdate
+	window *pricewindow.Window
+	series *indicators.Series
+	live   bool // indicators include the open candle
+	now    time.Time
+}
+
+func (e conditionEnv) Price() float64 { return e.update.Price }
+
+func (e conditionEnv) Size() float64 { return e.update.Size }
+
+func (e conditionEnv) Change(window time.Duration) (float64, bool) {
+	base, ok := e.window.CloseAt(e.now.Add(-window))
+	if !ok || base == 0 {
+		return 0, false
+	}
+	return (e.update.Price - base) / base * 100, true
+}
+
+func (e conditionEnv) High(window time.Duration) (float64, bool) {
+	_, high, ok := e.window.Range(e.now.Add(-window))
+	return high, ok
+}
+
+func (e conditionEnv) Low(window time.Duration) (float64, bool) {
+	low, _, ok := e.window.Range(e.now.Add(-window))
+	return low, ok
+}
+
+func (e conditionEnv) SMA(n int) (float64, bool) { return e.series.SMA(n, e.live) }
+
+func (e conditionEnv) EMA(n int) (float64, bool) { return e.series.EMA(n, e.live) }
+
+func (e conditionEnv) RSI(n int) (float64, bool) { return e.series.RSI(n, e.live) }
+
+func (e conditionEnv) Bands(n int) (float64, float64, bool) { return e.series.Bands(n, e.live) }
+
+// trailLevel moves the extreme of a trailing alert with price and returns
+// the level the alert fires at. The extreme starts at the alert's reference
+// price and is kept in Redis, so it survives restarts.
+func trailLevel(ctx context.Context, alert *models.Alert, price float64) (float64, bool) {
+	start := price
+	if alert.ReferencePrice != nil {
+		start = *alert.ReferencePrice
+	}
+	extreme, err := alertstate.TrackExtreme(ctx, alert, price, start)
+	if err != nil {
+		log.Println("❌ Failed to update trailing extreme:", err)
+		return 0, false
+	}
+	return alertstate.TrailLevel(alert, extreme), true
+}
+
+// percentMove measures the move a change alert watches, in percent, and its
+// direction. ok is false while there is no price to measure against.
+func percentMove(alert *models.Alert, price float64, window *pricewindow.Window, now time.Time) (move float64, direction string, ok bool) {
+	var up, down float64
+
+	switch alert.Type {
+	case models.AlertTypeReferenceChange:
+		if alert.ReferencePrice == nil {
+			return 0, "", false
+		}
+		change := (price - *alert.ReferencePrice) / *alert.ReferencePrice * 100
+		up, down = change, -change
+	case models.AlertTypeWindowChange, models.AlertTypeDrawdown:
+		low, high, found := window.Range(now.Add(-time.Duration(alert.WindowMinutes) * time.Minute))
+		if !found {
+			return 0, "", false
+		}
+		up = (price - low) / low * 100
+		down = (high - price) / high * 100
+	default:
+		return 0, "", false
+	}
+
+	switch alert.Direction {
+	case models.DirectionUp:
+		return up, models.DirectionUp, true
+	case models.DirectionDown:
+		return down, models.DirectionDown, true
+	}
+	if up >= down {
+		return up, models.DirectionUp, true
+	}
+	return down, models.DirectionDown, true
+}
+
+// volumeRatio compares the volume of the last window_minutes of a volume
+// spike alert, the current minute included, with its average over the
+// baseline_minutes before them. ok is false while the baseline is empty.
+func volumeRatio(alert *models.Alert, window *pricewindow.Window, now time.Time) (ratio, volume float64, ok bool) {
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	from := next.Add(-time.Duration(alert.WindowMinutes) * time.Minute)
+	volume = window.Volume(from, next)
+	baseline := window.Volume(from.Add(-time.Duration(alert.BaselineWindow)*time.Minute), from)
+	if baseline <= 0 {
+		return 0, 0, false
+	}
+	average := baseline / float64(alert.BaselineWindow) * float64(alert.WindowMinutes)
+	return volume / average, volume, true
+}
+
+// isLargeTrade reports whether a trade is large enough for a large_trade
+// alert and on its side. Trades without a reported side only match alerts
+// on either side.
+func isLargeTrade(alert *models.Alert, priceUpdate models.PriceUpdate) bool {
+	if priceUpdate.Size < *alert.MinSize {
+		return false
+	}
+	return alert.TradeSide == "" || alert.TradeSide == priceUpdate.Side
+}
+
+// triggerAlert reports whether an alert firing on a single event fires now,
+// recording the firing against the cooldown
+func triggerAlert(ctx context.Context, alert *models.Alert, now time.Time) bool {
+	outcome, err := alertstate.Trigger(ctx, alert.ID, now, alertCooldown(alert))
+	if err != nil {
+		log.Println("❌ Failed to update alert state:", err)
+		return false
+	}
+
+	if outcome == alertstate.CoolingDown {
+		fmt.Printf("⏳ Alert suppressed for %s (cooldown active)\n", alert.ID)
+	}
+	return outcome == alertstate.Fire
+}
+
+// shouldFire records the side price is on for one threshold of alert and
+// reports whether the alert fires: the price just crossed onto firingSide,
+// or has stayed there as long as the alert must sustain it, and the alert
+// is not cooling down. The check and the update of the shared state in
+// Redis are atomic, so replicas never double-fire.
+func shouldFire(ctx context.Context, alert *models.Alert, threshold, side, firingSide string, price float64, direction string) bool {
+	if side == "" {
+		// Inside the hysteresis band: the last side still holds
+		return false
+	}
+
+	outcome, err := alertstate.Observe(ctx, alert.ID, alertstate.Observation{
+		Threshold:      threshold,
+		Side:           side,
+		FiringSide:     firingSide,
+		Price:          price,
+		Direction:      direction,
+		Now:            time.Now(),
+		Cooldown:       alertCooldown(alert),
+		SustainFor:     time.Duration(alert.SustainSeconds) * time.Second,
+		SustainUpdates: alert.SustainUpdates,
+	})
+	if err != nil {
+		log.Println("❌ Failed to update alert state:", err)
+		return false
+	}
+
+	if outcome == alertstate.CoolingDown {
+		fmt.Printf("⏳ Alert suppressed for %s (cooldown active)\n", alert.ID)
+	}
+	return outcome == alertstate.Fire
+}
+
+// alertCooldown is the minimum time between two firings of alert
+func alertCooldown(alert *models.Alert) time.Duration {
+	switch {
+	case alert.Cooldown == models.NoCooldown:
+		return 0
+	case alert.Cooldown > 0:
+		return time.Duration(alert.Cooldown) * time.Second
+	}
+	return cooldown
+}
+
+// sustainCheckInterval is how late a sustained alert may fire when no price
+// update arrives once its duration is up
+const sustainCheckInterval = time.Second
+
+// expireSustainedAlerts fires sustained alerts whose duration ran out
+// between price updates, including ones left pending by a restart
+func expireSustainedAlerts(ctx context.Context) {
+	ticker := time.NewTicker(sustainCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			fired, err := alertstate.ExpireSustained(ctx, now, sustainedCooldown)
+			if err != nil {
+				log.Println("❌ Failed to check sustained alerts:", err)
+			}
+			for _, s := range fired {
+				alert, ok := alertIndex.Get(s.AlertID)
+				if !ok {
+					continue
+				}
+				priceUpdate := models.PriceUpdate{Exchange: alert.Exchange, Symbol: alert.Symbol, Price: s.Price}
+				fireAlert(ctx, alert, priceUpdate, firingThreshold(ctx, alert, s.Threshold, s.Price), s.Direction)
+			}
+		}
+	}
+}
+
+// sustainedCooldown is the cooldown of an alert with a pending sustained
+// firing. Alerts no longer indexed are not fired either way.
+func sustainedCooldown(alertID string) time.Duration {
+	if alert, ok := alertIndex.Get(alertID); ok {
+		return alertCooldown(alert)
+	}
+	return cooldown
+}
+
+// firingThreshold is the level recorded with a firing of one threshold of
+// alert, the one processPriceUpdate passes to fireAlert
+func firingThreshold(ctx context.Context, alert *models.Alert, threshold string, price float64) float64 {
+	if threshold == alertstate.Trail {
+		if extreme, ok, err := alertstate.Extreme(ctx, alert.ID); err == nil && ok {
+			return alertstate.TrailLevel(alert, extreme)
+		}
+	}
+
+	switch {
+	case threshold == alertstate.Upper && alert.UpperThreshold != nil:
+		return *alert.UpperThreshold
+	case threshold == alertstate.Lower && alert.LowerThreshold != nil:
+		return *alert.LowerThreshold
+	case threshold == alertstate.Move && alert.ChangePct != nil:
+		return *alert.ChangePct
+	case threshold == alertstate.Volume && alert.Multiplier != nil:
+		return *alert.Multiplier
+	default:
+		return price
+	}
+}
+
+// fireAlert records a firing in triggered_alerts together with one outbox
+// entry per delivery channel. The dispatcher performs the actual deliveries.
+func fireAlert(ctx context.Context, alert *models.Alert, priceUpdate models.PriceUpdate, threshold float64, direction string) {
+	triggered := &models.TriggeredAlert{
+		ID:             uuid.New().String(),
+		AlertID:        alert.ID,
+		UserID:         alert.UserID,
+		Symbol:         priceUpdate.Symbol,
+		Exchange:       priceUpdate.Exchange,
+		Price:          priceUpdate.Price,
+		Threshold:      threshold,
+		Direction:      direction,
+		Size:           priceUpdate.Size,
+		TriggeredAt:    time.Now().UTC(),
+		DeliveryStatus: models.DeliveryPending,
+	}
+
+	var entries []*models.OutboxEntry
+	if alert.EscalationID != "" {
+		// The escalation policy replaces the user's routing: the dispatcher
+		// sends each of its steps until the alert is acknowledged
+		triggered.EscalationID = alert.EscalationID
+		triggered.NextEscalationAt = &triggered.TriggeredAt
+		fmt.Printf("🚀 Triggering alert %s with escalation policy %s\n", triggered.AlertID, alert.EscalationID)
+	} else {
+		entries = outboxEntries(ctx, triggered, notificationChannels(ctx, triggered))
+		if len(entries) == 0 {
+			triggered.DeliveryStatus = models.DeliverySuppressed
+		}
+		fmt.Printf("🚀 Triggering alert %s on %d channel(s)\n", triggered.AlertID, len(entries))
+	}
+
+	err := database.CreateTriggeredAlert(ctx, triggered, entries)
+	if errors.Is(err, database.ErrAlertInactive) {
+		fmt.Printf("⏹ Alert %s is no longer active, not firing\n", triggered.AlertID)
+		return
+	}
+	if err != nil {
+		log.Println("❌ Failed to record triggered alert:", err)
+	}
+}
+
+// notificationChannels applies the user's preferences to a firing. Once the
+// user's hourly cap is reached, only in-app delivery remains for the rest of
+// the hour.
+func notificationChannels(ctx context.Context, triggered *models.TriggeredAlert) []string {
+	prefs, err := database.GetNotificationPreferences(ctx, triggered.UserID)
+	if err != nil {
+		if !errors.Is(err, database.ErrPreferencesNotFound) {
+			log.Println("❌ Failed to fetch notification preferences:", err)
+		}
+		prefs = notify.DefaultPreferences(triggered.UserID)
+	}
+
+	channels := notify.Route(prefs, triggered.Symbol, triggered.TriggeredAt)
+	if prefs.MaxPerHour <= 0 || len(notify.InAppOnly(channels)) == len(channels) {
+		return channels
+	}
+
+	hour := triggered.TriggeredAt.Truncate(time.Hour).Unix()
+	sent, err := cache.IncrementCounter(ctx, fmt.Sprintf("notifications:%s:%d", triggered.UserID, hour), time.Hour)
+	if err != nil {
+		log.Println("❌ Failed to count notifications:", err)
+		return channels
+	}
+	if sent > int64(prefs.MaxPerHour) {
+		fmt.Printf("⏳ Hourly notification cap reached for %s\n", triggered.UserID)
+		return notify.InAppOnly(channels)
+	}
+	return channels
+}
+
+// outboxEntries lists the deliveries of a firing on channels: the SSE
+// stream, each active webhook and the email address of the user
+func outboxEntries(ctx context.Context, triggered *models.TriggeredAlert, channels []string) []*models.OutboxEntry {
+	now := time.Now().UTC()
+	newEntry := func(channel, target string) *models.OutboxEntry {
+		return notify.NewOutboxEntry(triggered, channel, target, now)
+	}
+
+	entries := []*models.OutboxEntry{}
+	for _, channel := range channels {
+		switch channel {
+		case models.ChannelSSE:
+			entries = append(entries, newEntry(models.ChannelSSE, ""))
+		case models.ChannelWebhook:
+			hooks, err := database.GetWebhooksByUserID(ctx, triggered.UserID)
+			if err != nil {
+				log.Println("❌ Failed to fetch webhooks:", err)
+			}
+			for _, hook := range hooks {
+				if hook.Active {
+					entries = append(entries, newEntry(models.ChannelWebhook, hook.ID))
+				}
+			}
+		case models.ChannelEmail:
+			contact, err := database.GetUserContact(ctx, triggered.UserID, models.ChannelEmail)
+			if err == nil {
+				entries = append(entries, newEntry(models.ChannelEmail, contact.Address))
+			} else if !errors.Is(err, database.ErrContactNotFound) {
+				log.Println("❌ Failed to fetch email address:", err)
+			}
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,184 @@
+package aggregation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+// Method selects how per-venue trades are combined into one price
+type Method string
+
+const (
+	// VWAP is the volume-weighted average of every trade in the window
+	VWAP Method = "vwap"
+	// Median is the median of each venue's latest price in the window
+	Median Method = "median"
+	// Mid is the midpoint between the lowest and highest venue's latest
+	// price, the trade-only approximation of a best bid/ask mid
+	Mid Method = "mid"
+)
+
+// ParseMethod validates a method name from config
+func ParseMethod(name string) (Method, error) {
+	switch method := Method(name); method {
+	case VWAP, Median, Mid:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown aggregation method %q (supported: vwap, median, mid)", name)
+	}
+}
+
+// trade is a single per-venue print held in the window
+type trade struct {
+	exchange string
+	price    float64
+	size     float64
+	at       time.Time
+}
+
+// Aggregator keeps a sliding window of recent trades per symbol across all
+// venues and computes a consolidated price from it
+type Aggregator struct {
+	method Method
+	window time.Duration
+
+	mu     sync.Mutex
+	trades map[string][]trade
+	dirty  map[string]bool
+	volume map[string]float64 // traded since the previous consolidated price
+}
+
+// New creates an aggregator using method over a sliding window
+func New(method Method, window time.Duration) *Aggregator {
+	return &Aggregator{
+		method: method,
+		window: window,
+		trades: make(map[string][]trade),
+		dirty:  make(map[string]bool),
+		volume: make(map[string]float64),
+	}
+}
+
+// Add records a trade received at the given time. Arrival time rather than
+// the venue timestamp is used so clock skew between venues cannot push
+// trades out of the window.
+func (a *Aggregator) Add(update models.PriceUpdate, at time.Time) {
+	if update.Price <= 0 || update.Exchange == models.ConsolidatedExchange {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.trades[update.Symbol] = append(a.trades[update.Symbol], trade{
+		exchange: update.Exchange,
+		price:    update.Price,
+		size:     update.Size,
+		at:       at,
+	})
+	a.dirty[update.Symbol] = true
+	a.volume[update.Symbol] += update.Size
+}
+
+// Consolidate returns a consolidated price for every symbol that received
+// trades since the previous call, with the volume of those trades as its
+// size, evicting trades older than the window
+func (a *Aggregator) Consolidate(now time.Time) []models.PriceUpdate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-a.window)
+	var updates []models.PriceUpdate
+
+	for symbol, trades := range a.trades {
+		trades = evict(trades, cutoff)
+		if len(trades) == 0 {
+			delete(a.trades, symbol)
+			delete(a.dirty, symbol)
+			delete(a.volume, symbol)
+			continue
+		}
+		a.trades[symbol] = trades
+
+		if !a.dirty[symbol] {
+			continue
+		}
+		delete(a.dirty, symbol)
+
+		updates = append(updates, models.PriceUpdate{
+			Exchange:  models.ConsolidatedExchange,
+			Symbol:    symbol,
+			Price:     a.price(trades),
+			Size:      a.volume[symbol],
+			Timestamp: now.UTC().Format(time.RFC3339Nano),
+		})
+		delete(a.volume, symbol)
+	}
+
+	return updates
+}
+
+// price combines the trades of one symbol using the configured method
+func (a *Aggregator) price(trades []trade) float64 {
+	switch a.method {
+	case Median:
+		return median(latestByVenue(trades))
+	case Mid:
+		latest := latestByVenue(trades)
+		sort.Float64s(latest)
+		return (latest[0] + latest[len(latest)-1]) / 2
+	default:
+		return vwap(trades)
+	}
+}
+
+// evict drops trades older than cutoff; trades are ordered by arrival
+func evict(trades []trade, cutoff time.Time) []trade {
+	i := sort.Search(len(trades), func(i int) bool { return !trades[i].at.Before(cutoff) })
+	if i == 0 {
+		return trades
+	}
+	return append(trades[:0], trades[i:]...)
+}
+
+// vwap is the volume-weighted average price, falling back to the plain
+// mean when no trade carries a size
+func vwap(trades []trade) float64 {
+	var notional, volume, sum float64
+	for _, t := range trades {
+		notional += t.price * t.size
+		volume += t.size
+		sum += t.price
+	}
+	if volume == 0 {
+		return sum / float64(len(trades))
+	}
+	return notional / volume
+}
+
+// latestByVenue returns the most recent price of each venue in the window
+func latestByVenue(trades []trade) []float64 {
+	latest := make(map[string]float64)
+	for _, t := range trades {
+		latest[t.exchange] = t.price
+	}
+	prices := make([]float64, 0, len(latest))
+	for _, price := range latest {
+		prices = append(prices, price)
+	}
+	return prices
+}
+
+// median of a non-empty slice; sorts prices in place
+func median(prices []float64) float64 {
+	sort.Float64s(prices)
+	n := len(prices)
+	if n%2 == 1 {
+		return prices[n/2]
+	}
+	return (prices[n/2-1] + prices[n/2]) / 2
+}
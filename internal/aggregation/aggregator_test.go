@@ -0,0 +1,144 @@
+package aggregation
+
+import (
+	"testing"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// venueTrade is a trade arriving seconds after start
+type venueTrade struct {
+	exchange    string
+	price, size float64
+	seconds     int
+}
+
+func TestConsolidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		method Method
+		trades []venueTrade
+		now    int // seconds after start
+		want   float64
+		size   float64
+	}{
+		{
+			name:   "vwap weighs every trade by size",
+			method: VWAP,
+			trades: []venueTrade{{"coinbase", 100, 1, 0}, {"kraken", 110, 3, 1}, {"coinbase", 104, 1, 2}},
+			now:    5,
+			want:   (100 + 330 + 104) / 5.0,
+			size:   5,
+		},
+		{
+			name:   "vwap without sizes is the plain mean",
+			method: VWAP,
+			trades: []venueTrade{{"coinbase", 100, 0, 0}, {"kraken", 110, 0, 1}},
+			now:    5,
+			want:   105,
+		},
+		{
+			name:   "median of the latest price per venue",
+			method: Median,
+			trades: []venueTrade{{"coinbase", 90, 1, 0}, {"kraken", 110, 1, 1}, {"binance", 101, 1, 2}, {"coinbase", 100, 1, 3}},
+			now:    5,
+			want:   101,
+			size:   4,
+		},
+		{
+			name:   "median of an even number of venues",
+			method: Median,
+			trades: []venueTrade{{"coinbase", 100, 1, 0}, {"kraken", 104, 1, 1}},
+			now:    5,
+			want:   102,
+			size:   2,
+		},
+		{
+			name:   "mid between the lowest and highest venue",
+			method: Mid,
+			trades: []venueTrade{{"coinbase", 100, 1, 0}, {"kraken", 101, 1, 1}, {"binance", 110, 1, 2}},
+			now:    5,
+			want:   105,
+			size:   3,
+		},
+		{
+			name:   "stale venues fall out of the median",
+			method: Median,
+			trades: []venueTrade{{"bitstamp", 50, 1, 0}, {"coinbase", 100, 1, 8}, {"kraken", 104, 1, 9}},
+			now:    11, // the bitstamp trade is older than the 10s window
+			want:   102,
+			size:   3,
+		},
+		{
+			name:   "stale trades fall out of the vwap, their volume still counts",
+			method: VWAP,
+			trades: []venueTrade{{"bitstamp", 50, 10, 0}, {"coinbase", 100, 1, 8}},
+			now:    11,
+			want:   100,
+			size:   11,
+		},
+	}
+
+	for _, tt := range tests {
+		a := New(tt.method, 10*time.Second)
+		for _, p := range tt.trades {
+			a.Add(models.PriceUpdate{Exchange: p.exchange, Symbol: "BTC-USD", Price: p.price, Size: p.size},
+				start.Add(time.Duration(p.seconds)*time.Second))
+		}
+
+		updates := a.Consolidate(start.Add(time.Duration(tt.now) * time.Second))
+		if len(updates) != 1 {
+			t.Errorf("%s: %d updates, want 1", tt.name, len(updates))
+			continue
+		}
+		got := updates[0]
+		if got.Price != tt.want || got.Size != tt.size {
+			t.Errorf("%s: price %v size %v, want %v and %v", tt.name, got.Price, got.Size, tt.want, tt.size)
+		}
+		if got.Exchange != models.ConsolidatedExchange || got.Symbol != "BTC-USD" {
+			t.Errorf("%s: update for %s on %s", tt.name, got.Symbol, got.Exchange)
+		}
+	}
+}
+
+func TestConsolidateOnlyNewTrades(t *testing.T) {
+	a := New(VWAP, 10*time.Second)
+	a.Add(models.PriceUpdate{Exchange: "coinbase", Symbol: "BTC-USD", Price: 100, Size: 1}, start)
+	a.Add(models.PriceUpdate{Exchange: "coinbase", Symbol: "ETH-USD", Price: 10, Size: 1}, start)
+	// Consolidated prices and prints without a price are not venue trades
+	a.Add(models.PriceUpdate{Exchange: models.ConsolidatedExchange, Symbol: "BTC-USD", Price: 1, Size: 1}, start)
+	a.Add(models.PriceUpdate{Exchange: "kraken", Symbol: "BTC-USD", Price: 0, Size: 1}, start)
+
+	if updates := a.Consolidate(start.Add(time.Second)); len(updates) != 2 {
+		t.Fatalf("first Consolidate = %d updates, want 2", len(updates))
+	}
+	if updates := a.Consolidate(start.Add(2 * time.Second)); len(updates) != 0 {
+		t.Errorf("Consolidate without new trades = %v, want none", updates)
+	}
+
+	// The window still holds the first trade; the size counts only the new one
+	a.Add(models.PriceUpdate{Exchange: "kraken", Symbol: "BTC-USD", Price: 110, Size: 3}, start.Add(3*time.Second))
+	updates := a.Consolidate(start.Add(4 * time.Second))
+	if len(updates) != 1 || updates[0].Price != 107.5 || updates[0].Size != 3 {
+		t.Errorf("Consolidate after a new trade = %+v, want BTC-USD at 107.5 with size 3", updates)
+	}
+
+	// Once every trade is stale the symbol is forgotten
+	if updates := a.Consolidate(start.Add(time.Minute)); len(updates) != 0 || len(a.trades) != 0 {
+		t.Errorf("Consolidate after the window = %v with %d symbols held, want none", updates, len(a.trades))
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	for _, name := range []string{"vwap", "median", "mid"} {
+		if method, err := ParseMethod(name); err != nil || string(method) != name {
+			t.Errorf("ParseMethod(%q) = %q, %v", name, method, err)
+		}
+	}
+	if _, err := ParseMethod("twap"); err == nil {
+		t.Error("ParseMethod(\"twap\") succeeded")
+	}
+}
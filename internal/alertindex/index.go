@@ -0,0 +1,378 @@
+// Package alertindex keeps the alerts of every price stream in memory,
+// sorted by threshold, so that evaluating a price update is a range lookup
+// rather than a database query.
+package alertindex
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"pricenotification/internal/models"
+)
+
+// seriesKey identifies one price stream
+type seriesKey struct {
+	symbol   string
+	exchange string
+}
+
+// entry is one threshold of an alert
+type entry struct {
+	level float64
+	alert *models.Alert
+}
+
+// levels is a list of thresholds sorted by level
+type levels struct {
+	entries       []entry
+	maxHysteresis float64
+}
+
+func (l *levels) insert(level float64, alert *models.Alert) {
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].level >= level })
+	l.entries = append(l.entries, entry{})
+	copy(l.entries[i+1:], l.entries[i:])
+	l.entries[i] = entry{level: level, alert: alert}
+	l.maxHysteresis = max(l.maxHysteresis, alert.Hysteresis)
+}
+
+func (l *levels) remove(alertID string) {
+	kept := l.entries[:0]
+	l.maxHysteresis = 0
+	for _, e := range l.entries {
+		if e.alert.ID != alertID {
+			kept = append(kept, e)
+			l.maxHysteresis = max(l.maxHysteresis, e.alert.Hysteresis)
+		}
+	}
+	l.entries = kept
+}
+
+// between calls fn for every entry with from <= level <= to
+func (l *levels) between(from, to float64, fn func(*models.Alert)) {
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].level >= from })
+	for ; i < len(l.entries) && l.entries[i].level <= to; i++ {
+		fn(l.entries[i].alert)
+	}
+}
+
+// series holds the alerts of one price stream and its last price
+type series struct {
+	upper     levels
+	lower     levels
+	alerts    map[string]*models.Alert
+	always    map[string]*models.Alert // alerts evaluated on every update
+	fresh     map[string]bool          // alerts not evaluated since they were indexed
+	lastPrice float64
+	hasPrice  bool
+}
+
+func newSeries() *series {
+	return &series{
+		alerts: make(map[string]*models.Alert),
+		always: make(map[string]*models.Alert),
+		fresh:  make(map[string]bool),
+	}
+}
+
+// Index holds alerts by price stream. It is safe for concurrent use.
+type Index struct {
+	mu     sync.Mutex
+	byID   map[string]*models.Alert
+	series map[seriesKey]*series
+
+	// anyVenue holds large_trade alerts on the consolidated stream by
+	// symbol. Consolidated prices carry volume rather than single trades, so
+	// these alerts watch the trades of every venue instead.
+	anyVenue map[string]map[string]*models.Alert
+}
+
+// New creates an empty index
+func New() *Index {
+	return &Index{
+		byID:     make(map[string]*models.Alert),
+		series:   make(map[seriesKey]*series),
+		anyVenue: make(map[string]map[string]*models.Alert),
+	}
+}
+
+// Len returns the number of indexed alerts
+func (x *Index) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.byID)
+}
+
+// Get returns an indexed alert by ID
+func (x *Index) Get(alertID string) (*models.Alert, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	alert, ok := x.byID[alertID]
+	return alert, ok
+}
+
+// TracksMoves reports whether a stream has alerts on percentage moves or
+// volume, which need its recent price range and volume
+func (x *Index) TracksMoves(symbol, exchange string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	s := x.series[seriesKey{symbol, exchange}]
+	if s == nil {
+		return false
+	}
+	for _, alert := range s.always {
+		switch alert.Type {
+		case models.AlertTypeThreshold, models.AlertTypeTrailing, models.AlertTypeSpread, models.AlertTypeLargeTrade:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Intervals returns the candle intervals the condition and indicator
+// alerts of a stream compute indicators over
+func (x *Index) Intervals(symbol, exchange string) []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	s := x.series[seriesKey{symbol, exchange}]
+	if s == nil {
+		return nil
+	}
+	var intervals []string
+	for _, alert := range s.always {
+		interval := ConditionInterval(alert)
+		if interval != "" && !slices.Contains(intervals, interval) {
+			intervals = append(intervals, interval)
+		}
+	}
+	return intervals
+}
+
+// ConditionInterval is the candle interval of a condition or indicator
+// alert: 1m for condition alerts, and "" for other alerts
+func ConditionInterval(alert *models.Alert) string {
+	switch alert.Type {
+	case models.AlertTypeCondition:
+		return "1m"
+	case models.AlertTypeIndicator:
+		return alert.Interval
+	default:
+		return ""
+	}
+}
+
+// TracksLegs reports whether a stream is a leg of a spread alert, whose
+// latest price the other leg's updates need
+func (x *Index) TracksLegs(symbol, exchange string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	s := x.series[seriesKey{symbol, exchange}]
+	if s == nil {
+		return false
+	}
+	for _, alert := range s.always {
+		if alert.Type == models.AlertTypeSpread {
+			return true
+		}
+	}
+	return false
+}
+
+// Set adds or replaces an alert. Alerts that are not active are dropped.
+func (x *Index) Set(alert *models.Alert) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.set(alert)
+}
+
+// Remove drops an alert
+func (x *Index) Remove(alertID string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(alertID)
+}
+
+// Replace makes the active ones of alerts the full content of the index.
+// Alerts that are new or changed since they were last indexed are evaluated
+// on the next update.
+func (x *Index) Replace(alerts []*models.Alert) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	keep := make(map[string]bool, len(alerts))
+	for _, alert := range alerts {
+		keep[alert.ID] = true
+		// Firings and expiry change the status without touching updated_at
+		if old, ok := x.byID[alert.ID]; ok && old.UpdatedAt.Equal(alert.UpdatedAt) && old.Status == alert.Status {
+			continue
+		}
+		x.set(alert)
+	}
+	for id := range x.byID {
+		if !keep[id] {
+			x.remove(id)
+		}
+	}
+}
+
+func (x *Index) set(alert *models.Alert) {
+	x.remove(alert.ID)
+	if alert.Status != models.StatusActive {
+		return
+	}
+
+	if isAnyVenue(alert) {
+		if x.anyVenue[alert.Symbol] == nil {
+			x.anyVenue[alert.Symbol] = make(map[string]*models.Alert)
+		}
+		x.anyVenue[alert.Symbol][alert.ID] = alert
+		x.byID[alert.ID] = alert
+		return
+	}
+
+	s := x.seriesOf(seriesKey{alert.Symbol, alert.Exchange})
+
+	if alert.Type == models.AlertTypeThreshold && alert.SustainUpdates == 0 {
+		if alert.UpperThreshold != nil {
+			s.upper.insert(*alert.UpperThreshold, alert)
+		}
+		if alert.LowerThreshold != nil {
+			s.lower.insert(*alert.LowerThreshold, alert)
+		}
+	} else {
+		// Percentage moves, trailing levels, spreads and volume can change
+		// with every update, and consecutive updates past a threshold
+		// count towards sustain_updates
+		s.always[alert.ID] = alert
+	}
+	s.alerts[alert.ID] = alert
+	// Its crossing state must be recorded before range lookups can skip it
+	s.fresh[alert.ID] = true
+	x.byID[alert.ID] = alert
+
+	if alert.Type == models.AlertTypeSpread {
+		// A move of either leg moves the spread
+		leg := x.seriesOf(seriesKey{alert.LegSymbol, alert.LegExchange})
+		leg.always[alert.ID] = alert
+		leg.alerts[alert.ID] = alert
+	}
+}
+
+// isAnyVenue reports whether an alert watches the trades of every venue
+func isAnyVenue(alert *models.Alert) bool {
+	return alert.Type == models.AlertTypeLargeTrade && alert.Exchange == models.ConsolidatedExchange
+}
+
+// seriesOf returns the series of a stream, creating it when needed
+func (x *Index) seriesOf(key seriesKey) *series {
+	s := x.series[key]
+	if s == nil {
+		s = newSeries()
+		x.series[key] = s
+	}
+	return s
+}
+
+func (x *Index) remove(alertID string) {
+	alert, ok := x.byID[alertID]
+	if !ok {
+		return
+	}
+	delete(x.byID, alertID)
+
+	if isAnyVenue(alert) {
+		delete(x.anyVenue[alert.Symbol], alertID)
+		if len(x.anyVenue[alert.Symbol]) == 0 {
+			delete(x.anyVenue, alert.Symbol)
+		}
+		return
+	}
+
+	keys := []seriesKey{{alert.Symbol, alert.Exchange}}
+	if alert.Type == models.AlertTypeSpread {
+		keys = append(keys, seriesKey{alert.LegSymbol, alert.LegExchange})
+	}
+	for _, key := range keys {
+		s := x.series[key]
+		s.upper.remove(alertID)
+		s.lower.remove(alertID)
+		delete(s.alerts, alertID)
+		delete(s.always, alertID)
+		delete(s.fresh, alertID)
+		if len(s.alerts) == 0 {
+			delete(x.series, key)
+		}
+	}
+}
+
+// Candidates records update as the last price of its stream and returns the
+// alerts whose state it may change: those with a threshold between the
+// previous and the new price, widened by the largest hysteresis band, plus
+// alerts without a fixed level or counting sustained updates, and alerts
+// not evaluated yet. The first update of a stream returns every alert of
+// the stream. Updates of a venue also return the large_trade alerts of the
+// symbol on the consolidated stream.
+func (x *Index) Candidates(update models.PriceUpdate) []*models.Alert {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	candidates := x.streamCandidates(update)
+	if update.Exchange != models.ConsolidatedExchange {
+		for _, alert := range x.anyVenue[update.Symbol] {
+			candidates = append(candidates, alert)
+		}
+	}
+	return candidates
+}
+
+// streamCandidates returns the candidates among the alerts of the stream
+// of update
+func (x *Index) streamCandidates(update models.PriceUpdate) []*models.Alert {
+	s := x.series[seriesKey{update.Symbol, update.Exchange}]
+	if s == nil {
+		return nil
+	}
+
+	prev, hadPrice := s.lastPrice, s.hasPrice
+	s.lastPrice, s.hasPrice = update.Price, true
+
+	if !hadPrice {
+		clear(s.fresh)
+		candidates := make([]*models.Alert, 0, len(s.alerts))
+		for _, alert := range s.alerts {
+			candidates = append(candidates, alert)
+		}
+		return candidates
+	}
+
+	seen := make(map[string]bool)
+	var candidates []*models.Alert
+	add := func(alert *models.Alert) {
+		if !seen[alert.ID] {
+			seen[alert.ID] = true
+			candidates = append(candidates, alert)
+		}
+	}
+
+	low, high := min(prev, update.Price), max(prev, update.Price)
+	// An upper threshold re-arms under level - hysteresis, a lower one over
+	// level + hysteresis, so each side's range extends by its widest band
+	s.upper.between(low, high+s.upper.maxHysteresis, add)
+	s.lower.between(low-s.lower.maxHysteresis, high, add)
+
+	for _, alert := range s.always {
+		add(alert)
+	}
+	for id := range s.fresh {
+		add(s.alerts[id])
+	}
+	clear(s.fresh)
+
+	return candidates
+}
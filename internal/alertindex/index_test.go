@@ -0,0 +1,174 @@
+package alertindex
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+func level(v float64) *float64 { return &v }
+
+func threshold(id string, upper, lower *float64, hysteresis float64) *models.Alert {
+	return &models.Alert{
+		ID: id, Symbol: "BTC-USD", Exchange: "coinbase", Type: models.AlertTypeThreshold,
+		UpperThreshold: upper, LowerThreshold: lower, Hysteresis: hysteresis, Status: models.StatusActive,
+	}
+}
+
+func update(exchange string, price float64) models.PriceUpdate {
+	return models.PriceUpdate{Symbol: "BTC-USD", Exchange: exchange, Price: price}
+}
+
+// ids returns the sorted IDs of alerts
+func ids(alerts []*models.Alert) []string {
+	out := []string{}
+	for _, alert := range alerts {
+		out = append(out, alert.ID)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestCandidates(t *testing.T) {
+	x := New()
+	x.Set(threshold("above-100", level(100), nil, 0))
+	x.Set(threshold("above-110-h5", level(110), nil, 5))
+	x.Set(threshold("below-90", nil, level(90), 0))
+	x.Set(threshold("band", level(120), level(80), 0))
+	x.Set(&models.Alert{ID: "move", Symbol: "BTC-USD", Exchange: "coinbase", Type: models.AlertTypeWindowChange, Status: models.StatusActive})
+	x.Set(threshold("paused", level(100), nil, 0))
+	paused := threshold("paused", level(100), nil, 0)
+	paused.Status = models.StatusPaused
+	x.Set(paused)
+
+	steps := []struct {
+		price float64
+		want  []string
+	}{
+		// The first price of a stream returns every alert
+		{95, []string{"above-100", "above-110-h5", "band", "below-90", "move"}},
+		// Upper levels are looked up 5 over the price, the widest band
+		{99, []string{"above-100", "move"}},
+		{101, []string{"above-100", "move"}},
+		// 110 may re-arm at 105, which 101 to 106 crosses, or re-fire
+		{106, []string{"above-110-h5", "move"}},
+		{104, []string{"above-110-h5", "move"}},
+		{89, []string{"above-100", "below-90", "move"}},
+		{121, []string{"above-100", "above-110-h5", "band", "below-90", "move"}},
+		{121, []string{"move"}},
+		{79, []string{"above-100", "above-110-h5", "band", "below-90", "move"}},
+	}
+
+	for _, step := range steps {
+		got := ids(x.Candidates(update("coinbase", step.price)))
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("Candidates at %v = %v, want %v", step.price, got, step.want)
+		}
+	}
+
+	// Other streams have their own alerts and last price
+	if got := x.Candidates(update("kraken", 100)); len(got) != 0 {
+		t.Errorf("Candidates on kraken = %v, want none", ids(got))
+	}
+}
+
+func TestCandidatesFresh(t *testing.T) {
+	x := New()
+	x.Set(threshold("above-100", level(100), nil, 0))
+	x.Candidates(update("coinbase", 95))
+
+	// Indexed after the first price with a level far away: evaluated once
+	// so its crossing state is recorded
+	x.Set(threshold("above-200", level(200), nil, 0))
+	if got := ids(x.Candidates(update("coinbase", 96))); !reflect.DeepEqual(got, []string{"above-200"}) {
+		t.Errorf("Candidates after Set = %v, want the fresh alert", got)
+	}
+	if got := x.Candidates(update("coinbase", 97)); len(got) != 0 {
+		t.Errorf("Candidates after it was evaluated = %v, want none", ids(got))
+	}
+
+	// Replacing it makes it fresh again, unless it did not change
+	changed := []*models.Alert{threshold("above-100", level(100), nil, 0), threshold("above-200", level(200), nil, 0)}
+	for _, alert := range changed {
+		alert.UpdatedAt = time.Now()
+	}
+	x.Replace(changed)
+	if got := x.Candidates(update("coinbase", 97)); len(got) != 2 {
+		t.Errorf("Candidates after Replace = %v, want both alerts", ids(got))
+	}
+	x.Replace([]*models.Alert{x.byID["above-200"]})
+	if got := x.Candidates(update("coinbase", 97)); len(got) != 0 || x.Len() != 1 {
+		t.Errorf("Candidates after an unchanged Replace = %v with %d alerts, want none of 1", ids(got), x.Len())
+	}
+
+	// A removed alert is gone, and so is a series without alerts
+	x.Remove("above-200")
+	if x.Len() != 0 || len(x.series) != 0 {
+		t.Errorf("index holds %d alerts in %d series after Remove", x.Len(), len(x.series))
+	}
+}
+
+func TestCandidatesSustainedUpdates(t *testing.T) {
+	x := New()
+	sustained := threshold("sustained", level(100), nil, 0)
+	sustained.SustainUpdates = 3
+	x.Set(sustained)
+	x.Candidates(update("coinbase", 101))
+
+	// Every update past the level counts, so it is never range-filtered
+	if got := ids(x.Candidates(update("coinbase", 102))); !reflect.DeepEqual(got, []string{"sustained"}) {
+		t.Errorf("Candidates = %v, want the sustained alert", got)
+	}
+}
+
+func TestCandidatesSpread(t *testing.T) {
+	x := New()
+	x.Set(&models.Alert{ID: "spread", Symbol: "BTC-USD", Exchange: "coinbase", Type: models.AlertTypeSpread,
+		LegSymbol: "BTC-USD", LegExchange: "kraken", Status: models.StatusActive})
+
+	if !x.TracksLegs("BTC-USD", "kraken") || !x.TracksLegs("BTC-USD", "coinbase") {
+		t.Error("TracksLegs is false for a leg of the spread")
+	}
+	// Either leg moves the spread, on every update
+	for _, exchange := range []string{"coinbase", "kraken", "coinbase", "kraken"} {
+		if got := ids(x.Candidates(update(exchange, 100))); !reflect.DeepEqual(got, []string{"spread"}) {
+			t.Errorf("Candidates on %s = %v, want the spread alert", exchange, got)
+		}
+	}
+
+	x.Remove("spread")
+	if x.TracksLegs("BTC-USD", "kraken") || len(x.series) != 0 {
+		t.Errorf("index holds %d series after Remove, want none", len(x.series))
+	}
+}
+
+func TestCandidatesAnyVenue(t *testing.T) {
+	x := New()
+	x.Set(&models.Alert{ID: "large", Symbol: "BTC-USD", Exchange: models.ConsolidatedExchange,
+		Type: models.AlertTypeLargeTrade, Status: models.StatusActive})
+
+	// Every venue's trades of the symbol, not the consolidated price
+	tests := []struct {
+		update models.PriceUpdate
+		want   []string
+	}{
+		{update("coinbase", 100), []string{"large"}},
+		{update("kraken", 101), []string{"large"}},
+		{update("kraken", 101), []string{"large"}},
+		{update(models.ConsolidatedExchange, 100), []string{}},
+		{models.PriceUpdate{Symbol: "ETH-USD", Exchange: "coinbase", Price: 10}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := ids(x.Candidates(tt.update)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Candidates of %s on %s = %v, want %v", tt.update.Symbol, tt.update.Exchange, got, tt.want)
+		}
+	}
+
+	x.Remove("large")
+	if got := x.Candidates(update("coinbase", 100)); len(got) != 0 || len(x.anyVenue) != 0 {
+		t.Errorf("Candidates after Remove = %v, want none", ids(got))
+	}
+}
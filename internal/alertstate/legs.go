@@ -0,0 +1,61 @@
+package alertstate
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pricenotification/internal/cache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// legPricesKey is the hash of the latest price of every stream that is a
+// leg of a spread alert. Price messages are keyed by symbol, so the legs of
+// one alert are usually consumed by different replicas.
+const legPricesKey = "leg_prices"
+
+func legField(symbol, exchange string) string {
+	return symbol + "|" + exchange
+}
+
+// LegPrice is the latest price of one leg and when it was seen
+type LegPrice struct {
+	Price float64
+	At    time.Time
+}
+
+// RecordLegPrice stores the latest price of a stream
+func RecordLegPrice(ctx context.Context, symbol, exchange string, price float64, at time.Time) error {
+	value := strconv.FormatFloat(price, 'f', -1, 64) + "|" + strconv.FormatInt(at.UnixMilli(), 10)
+	return cache.RedisClient.HSet(ctx, legPricesKey, legField(symbol, exchange), value).Err()
+}
+
+// GetLegPrice returns the latest price of a stream. ok is false when no
+// price has been recorded.
+func GetLegPrice(ctx context.Context, symbol, exchange string) (leg LegPrice, ok bool, err error) {
+	value, err := cache.RedisClient.HGet(ctx, legPricesKey, legField(symbol, exchange)).Result()
+	if errors.Is(err, redis.Nil) {
+		return LegPrice{}, false, nil
+	}
+	if err != nil {
+		return LegPrice{}, false, err
+	}
+
+	price, at, found := strings.Cut(value, "|")
+	if !found {
+		return LegPrice{}, false, nil
+	}
+	leg.Price, err = strconv.ParseFloat(price, 64)
+	if err != nil {
+		return LegPrice{}, false, err
+	}
+	ms, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return LegPrice{}, false, err
+	}
+	leg.At = time.UnixMilli(ms)
+	return leg, true, nil
+}
@@ -0,0 +1,332 @@
+// Package alertstate keeps per-alert evaluation state in Redis, so that it
+// survives restarts and is shared by every price processing replica.
+package alertstate
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pricenotification/internal/cache"
+	"pricenotification/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sides of a threshold
+const (
+	SideAbove = "above"
+	SideBelow = "below"
+)
+
+// Threshold names, used as hash fields
+const (
+	Upper     = "upper"
+	Lower     = "lower"
+	Move      = "move"      // the percentage move of a change alert
+	Condition = "condition" // whether a condition alert's expression holds
+	Trail     = "trail"     // the trigger level of a trailing alert
+	Volume    = "volume"    // the volume of a volume spike alert over its baseline
+)
+
+// key returns the Redis hash holding the state of one alert
+func key(alertID string) string {
+	return "alert_state:" + alertID
+}
+
+// Outcome of observing a price against one threshold
+type Outcome int
+
+const (
+	Unchanged   Outcome = iota // no crossing onto the firing side
+	Fire                       // crossed; the firing has been recorded
+	CoolingDown                // crossed, but the alert fired too recently
+	Sustaining                 // on the firing side, but not for long enough yet
+)
+
+// deadlinesKey is the sorted set of pending sustained firings, scored by the
+// time (ms) their duration is up and holding alertID|threshold members
+const deadlinesKey = "alert_sustain_deadlines"
+
+func member(alertID, threshold string) string {
+	return alertID + "|" + threshold
+}
+
+// Observation is one price placed against one threshold of an alert
+type Observation struct {
+	Threshold  string
+	Side       string // side of the threshold the price is on
+	FiringSide string // side the alert fires on
+	Price      float64
+	Direction  string // recorded with the firing
+	Now        time.Time
+	Cooldown   time.Duration
+
+	// An alert with a sustain requirement fires only once its firing side
+	// has held for SustainFor and for SustainUpdates consecutive updates
+	SustainFor     time.Duration
+	SustainUpdates int
+}
+
+// observeScript records the side of a threshold. A crossing onto the firing
+// side fires at once, or starts a pending firing when the alert must
+// sustain its condition; a pending firing fires on the update that meets
+// its requirement and is dropped when the side changes. Firing checks and
+// sets the alert's last trigger time against the cooldown. Running it all
+// in one script means two replicas seeing the same price can never both
+// fire.
+//
+// KEYS[1] state hash, KEYS[2] deadlines; ARGV: threshold, side, firing side,
+// now (ms), cooldown (ms), sustain (ms), sustain updates, price, direction,
+// deadline member
+var observeScript = redis.NewScript(`
+local th = ARGV[1]
+local pending, count = th .. '_pending_since', th .. '_pending_count'
+local prev = redis.call('HGET', KEYS[1], th .. '_side')
+redis.call('HSET', KEYS[1], th .. '_side', ARGV[2])
+if ARGV[2] ~= ARGV[3] then
+	if redis.call('HDEL', KEYS[1], pending, count) > 0 then
+		redis.call('ZREM', KEYS[2], ARGV[10])
+	end
+	return 0
+end
+local now, sustain, updates = tonumber(ARGV[4]), tonumber(ARGV[6]), tonumber(ARGV[7])
+local since, seen
+if prev == ARGV[2] then
+	since = tonumber(redis.call('HGET', KEYS[1], pending) or '-1')
+	if since < 0 then
+		return 0
+	end
+	seen = redis.call('HINCRBY', KEYS[1], count, 1)
+else
+	if not prev then
+		return 0
+	end
+	since, seen = now, 1
+	if sustain > 0 or updates > 1 then
+		redis.call('HSET', KEYS[1], pending, now, count, 1, th .. '_sustain_ms', sustain, th .. '_sustain_updates', updates)
+		if sustain > 0 then
+			redis.call('ZADD', KEYS[2], now + sustain, ARGV[10])
+		end
+	end
+end
+redis.call('HSET', KEYS[1], th .. '_price', ARGV[8], th .. '_direction', ARGV[9])
+if now - since < sustain or seen < updates then
+	return 3
+end
+redis.call('HDEL', KEYS[1], pending, count)
+redis.call('ZREM', KEYS[2], ARGV[10])
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_triggered_at') or '0')
+if now - last < tonumber(ARGV[5]) then
+	return 2
+end
+redis.call('HSET', KEYS[1], 'last_triggered_at', ARGV[4])
+return 1
+`)
+
+// Observe records the side of one threshold of an alert and reports whether
+// the alert fires. The first observation only records the side, so neither
+// new alerts nor restarts fire for an old crossing.
+func Observe(ctx context.Context, alertID string, o Observation) (Outcome, error) {
+	res, err := observeScript.Run(ctx, cache.RedisClient, []string{key(alertID), deadlinesKey},
+		o.Threshold, o.Side, o.FiringSide, o.Now.UnixMilli(), o.Cooldown.Milliseconds(),
+		o.SustainFor.Milliseconds(), o.SustainUpdates, o.Price, o.Direction, member(alertID, o.Threshold),
+	).Int()
+	if err != nil {
+		return Unchanged, err
+	}
+	return Outcome(res), nil
+}
+
+// expireScript fires a pending firing whose duration is up without a price
+// update to notice it. The pending firing must still be there: an update
+// may have fired or dropped it in the meantime. When it still waits for
+// more updates, the next qualifying update fires it.
+//
+// KEYS[1] state hash, KEYS[2] deadlines; ARGV: threshold, now (ms),
+// cooldown (ms), deadline member
+var expireScript = redis.NewScript(`
+local th = ARGV[1]
+local pending, count = th .. '_pending_since', th .. '_pending_count'
+if redis.call('ZREM', KEYS[2], ARGV[4]) == 0 then
+	return false
+end
+local since = tonumber(redis.call('HGET', KEYS[1], pending) or '-1')
+if since < 0 then
+	return false
+end
+local now = tonumber(ARGV[2])
+local sustain = tonumber(redis.call('HGET', KEYS[1], th .. '_sustain_ms') or '0')
+if now - since < sustain then
+	redis.call('ZADD', KEYS[2], since + sustain, ARGV[4])
+	return false
+end
+local updates = tonumber(redis.call('HGET', KEYS[1], th .. '_sustain_updates') or '0')
+if tonumber(redis.call('HGET', KEYS[1], count) or '0') < updates then
+	return false
+end
+redis.call('HDEL', KEYS[1], pending, count)
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_triggered_at') or '0')
+if now - last < tonumber(ARGV[3]) then
+	return false
+end
+redis.call('HSET', KEYS[1], 'last_triggered_at', ARGV[2])
+return {redis.call('HGET', KEYS[1], th .. '_price'), redis.call('HGET', KEYS[1], th .. '_direction')}
+`)
+
+// Sustained is a pending firing whose duration ran out
+type Sustained struct {
+	AlertID   string
+	Threshold string
+	Price     float64 // last price seen on the firing side
+	Direction string
+}
+
+// ExpireSustained fires the pending firings whose duration is up at now.
+// Deadlines live in Redis, so firings pending across a restart are not
+// lost, and each one is returned to exactly one caller. cooldown returns the
+// cooldown of an alert.
+func ExpireSustained(ctx context.Context, now time.Time, cooldown func(alertID string) time.Duration) ([]Sustained, error) {
+	due, err := cache.RedisClient.ZRangeByScore(ctx, deadlinesKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []Sustained
+	for _, m := range due {
+		alertID, threshold, ok := strings.Cut(m, "|")
+		if !ok {
+			cache.RedisClient.ZRem(ctx, deadlinesKey, m)
+			continue
+		}
+		res, err := expireScript.Run(ctx, cache.RedisClient, []string{key(alertID), deadlinesKey},
+			threshold, now.UnixMilli(), cooldown(alertID).Milliseconds(), m,
+		).StringSlice()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return fired, err
+		}
+		price, _ := strconv.ParseFloat(res[0], 64)
+		fired = append(fired, Sustained{AlertID: alertID, Threshold: threshold, Price: price, Direction: res[1]})
+	}
+	return fired, nil
+}
+
+// triggerScript fires an alert unless it is cooling down, for alerts that
+// fire on single events rather than on crossings
+//
+// KEYS[1] state hash; ARGV: now (ms), cooldown (ms)
+var triggerScript = redis.NewScript(`
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_triggered_at') or '0')
+if tonumber(ARGV[1]) - last < tonumber(ARGV[2]) then
+	return 2
+end
+redis.call('HSET', KEYS[1], 'last_triggered_at', ARGV[1])
+return 1
+`)
+
+// Trigger records a firing of an alert at now and returns Fire, or
+// CoolingDown when the alert fired less than cooldown ago
+func Trigger(ctx context.Context, alertID string, now time.Time, cooldown time.Duration) (Outcome, error) {
+	res, err := triggerScript.Run(ctx, cache.RedisClient, []string{key(alertID)}, now.UnixMilli(), cooldown.Milliseconds()).Int()
+	if err != nil {
+		return Unchanged, err
+	}
+	return Outcome(res), nil
+}
+
+// Reset forgets the state of an alert, e.g. after its thresholds changed
+func Reset(ctx context.Context, alertID string) error {
+	pipe := cache.RedisClient.TxPipeline()
+	pipe.Del(ctx, key(alertID))
+	pipe.ZRem(ctx, deadlinesKey, member(alertID, Upper), member(alertID, Lower), member(alertID, Move), member(alertID, Condition), member(alertID, Trail), member(alertID, Volume))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// trackExtremeScript moves the extreme of a trailing alert to price when
+// price goes beyond it, starting from the given extreme
+//
+// KEYS[1] state hash; ARGV: price, starting extreme, high or low
+var trackExtremeScript = redis.NewScript(`
+local extreme = redis.call('HGET', KEYS[1], 'extreme') or ARGV[2]
+local price = tonumber(ARGV[1])
+if (ARGV[3] == 'high' and price > tonumber(extreme)) or (ARGV[3] == 'low' and price < tonumber(extreme)) then
+	extreme = ARGV[1]
+end
+redis.call('HSET', KEYS[1], 'extreme', extreme)
+return extreme
+`)
+
+// TrackExtreme records price against the running extreme of a trailing
+// alert, the highest price seen or, with direction up, the lowest, and
+// returns the extreme. start is the extreme before any price was recorded.
+func TrackExtreme(ctx context.Context, alert *models.Alert, price, start float64) (float64, error) {
+	bound := "high"
+	if alert.Direction == models.DirectionUp {
+		bound = "low"
+	}
+	return trackExtremeScript.Run(ctx, cache.RedisClient, []string{key(alert.ID)}, price, start, bound).Float64()
+}
+
+// Extreme returns the running extreme of a trailing alert. ok is false
+// before price processing has recorded a price for it.
+func Extreme(ctx context.Context, alertID string) (extreme float64, ok bool, err error) {
+	extreme, err = cache.RedisClient.HGet(ctx, key(alertID), "extreme").Float64()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return extreme, true, nil
+}
+
+// TrailLevel is the price a trailing alert fires at given its extreme:
+// change_pct percent or trail_amount below a high, or above a low
+func TrailLevel(alert *models.Alert, extreme float64) float64 {
+	distance := 0.0
+	if alert.TrailAmount != nil {
+		distance = *alert.TrailAmount
+	} else if alert.ChangePct != nil {
+		distance = extreme * *alert.ChangePct / 100
+	}
+	if alert.Direction == models.DirectionUp {
+		return extreme + distance
+	}
+	return extreme - distance
+}
+
+// UpperSide places price relative to an upper threshold: above at or over
+// level, below once under level - band, and "" inside the band, where the
+// previous side still holds
+func UpperSide(price, level, band float64) string {
+	switch {
+	case price >= level:
+		return SideAbove
+	case price < level-band:
+		return SideBelow
+	default:
+		return ""
+	}
+}
+
+// LowerSide places price relative to a lower threshold: below at or under
+// level, above once over level + band, and "" inside the band
+func LowerSide(price, level, band float64) string {
+	switch {
+	case price <= level:
+		return SideBelow
+	case price > level+band:
+		return SideAbove
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,54 @@
+package alertstate
+
+import (
+	"testing"
+
+	"pricenotification/internal/models"
+)
+
+func TestSides(t *testing.T) {
+	tests := []struct {
+		price, level, band float64
+		upper, lower       string
+	}{
+		{100, 100, 0, SideAbove, SideBelow},
+		{101, 100, 0, SideAbove, SideAbove},
+		{99, 100, 0, SideBelow, SideBelow},
+		// Inside the band the previous side holds
+		{98, 100, 2, "", SideBelow},
+		{97.9, 100, 2, SideBelow, SideBelow},
+		{102, 100, 2, SideAbove, ""},
+		{102.1, 100, 2, SideAbove, SideAbove},
+	}
+
+	for _, tt := range tests {
+		if got := UpperSide(tt.price, tt.level, tt.band); got != tt.upper {
+			t.Errorf("UpperSide(%v, %v, %v) = %q, want %q", tt.price, tt.level, tt.band, got, tt.upper)
+		}
+		if got := LowerSide(tt.price, tt.level, tt.band); got != tt.lower {
+			t.Errorf("LowerSide(%v, %v, %v) = %q, want %q", tt.price, tt.level, tt.band, got, tt.lower)
+		}
+	}
+}
+
+func TestTrailLevel(t *testing.T) {
+	amount, pct := 500.0, 5.0
+	tests := []struct {
+		name    string
+		alert   models.Alert
+		extreme float64
+		want    float64
+	}{
+		{"amount below a peak", models.Alert{TrailAmount: &amount, Direction: models.DirectionDown}, 20000, 19500},
+		{"amount above a trough", models.Alert{TrailAmount: &amount, Direction: models.DirectionUp}, 20000, 20500},
+		{"percent of the peak", models.Alert{ChangePct: &pct, Direction: models.DirectionDown}, 20000, 19000},
+		{"percent of the trough", models.Alert{ChangePct: &pct, Direction: models.DirectionUp}, 20000, 21000},
+		{"amount wins over percent", models.Alert{TrailAmount: &amount, ChangePct: &pct, Direction: models.DirectionDown}, 20000, 19500},
+	}
+
+	for _, tt := range tests {
+		if got := TrailLevel(&tt.alert, tt.extreme); got != tt.want {
+			t.Errorf("%s: TrailLevel(%v) = %v, want %v", tt.name, tt.extreme, got, tt.want)
+		}
+	}
+}
@@ -127,3 +127,16 @@ func getAllKeys(ctx context.Context, prefix string) ([]string, error) {
 	}
 	return keys, nil
 }
+
+// IncrementCounter increments key and returns its new value. The key
+// expires ttl after its last increment; with the window start in the key
+// this makes a fixed window counter.
+func IncrementCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := RedisClient.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
@@ -0,0 +1,95 @@
+// internal/cache/streams.go
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AppendToStream adds payload to a Redis stream capped at roughly maxLen
+// entries and returns the ID Redis assigned to it
+func AppendToStream(ctx context.Context, stream, field, payload string, maxLen int64) (string, error) {
+	return RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: map[string]interface{}{field: payload},
+	}).Result()
+}
+
+// LastStreamID returns the ID of the newest entry in a stream, or "0-0" when
+// the stream is empty or does not exist yet
+func LastStreamID(ctx context.Context, stream string) (string, error) {
+	entries, err := RedisClient.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// ReadStream blocks up to block for entries after lastID. It returns an
+// empty slice when the wait times out.
+func ReadStream(ctx context.Context, stream, lastID string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	result, err := RedisClient.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{stream, lastID},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result[0].Messages, nil
+}
+
+// RangeStreamAfter returns up to count entries with IDs strictly greater
+// than afterID
+func RangeStreamAfter(ctx context.Context, stream, afterID string, count int64) ([]redis.XMessage, error) {
+	return RedisClient.XRangeN(ctx, stream, "("+afterID, "+", count).Result()
+}
+
+// CompareStreamIDs orders two stream IDs ("<ms>-<seq>"), returning -1, 0 or 1.
+// Malformed IDs compare as 0-0.
+func CompareStreamIDs(a, b string) int {
+	aMs, aSeq := parseStreamID(a)
+	bMs, bSeq := parseStreamID(b)
+	switch {
+	case aMs < bMs || (aMs == bMs && aSeq < bSeq):
+		return -1
+	case aMs == bMs && aSeq == bSeq:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// ValidStreamID reports whether id is a complete stream ID
+func ValidStreamID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, errMs := strconv.ParseUint(ms, 10, 64)
+	_, errSeq := strconv.ParseUint(seq, 10, 64)
+	return errMs == nil && errSeq == nil
+}
+
+func parseStreamID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msVal, _ := strconv.ParseUint(ms, 10, 64)
+	seqVal, _ := strconv.ParseUint(seq, 10, 64)
+	return msVal, seqVal
+}
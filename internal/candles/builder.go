@@ -0,0 +1,171 @@
+package candles
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+// Intervals maps supported interval names to their durations
+var Intervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// ParseIntervals validates interval names from config
+func ParseIntervals(names []string) ([]string, error) {
+	for _, name := range names {
+		if _, ok := Intervals[name]; !ok {
+			return nil, fmt.Errorf("unknown candle interval %q (supported: 1m, 5m, 1h, 1d)", name)
+		}
+	}
+	return names, nil
+}
+
+// TradeTime parses a PriceUpdate timestamp, falling back to fallback when
+// the venue sent none or an unparseable one
+func TradeTime(update models.PriceUpdate, fallback time.Time) time.Time {
+	if at, err := time.Parse(time.RFC3339Nano, update.Timestamp); err == nil {
+		return at.UTC()
+	}
+	return fallback.UTC()
+}
+
+// seriesKey identifies one candle series
+type seriesKey struct {
+	symbol   string
+	exchange string
+	interval string
+}
+
+// candleKey identifies one candle within a series
+type candleKey struct {
+	series   seriesKey
+	openTime int64
+}
+
+// Builder aggregates trades into the open candle of every series. Candles
+// changed since the last Drain are marked dirty so callers can persist
+// incrementally.
+type Builder struct {
+	intervals []string
+
+	mu    sync.Mutex
+	open  map[seriesKey]*models.Candle
+	dirty map[candleKey]*models.Candle
+}
+
+// NewBuilder creates a builder for the given interval names
+func NewBuilder(intervals []string) *Builder {
+	return &Builder{
+		intervals: intervals,
+		open:      make(map[seriesKey]*models.Candle),
+		dirty:     make(map[candleKey]*models.Candle),
+	}
+}
+
+// Add applies a trade at time at to every interval. Trades older than the
+// series' open candle arrive too late and are dropped. Consolidated prices
+// build their own series; they are not trades, since every trade behind
+// them is already counted on its venue, so they add no trade count.
+func (b *Builder) Add(update models.PriceUpdate, at time.Time) {
+	if update.Price <= 0 {
+		return
+	}
+	consolidated := update.Exchange == models.ConsolidatedExchange
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, interval := range b.intervals {
+		key := seriesKey{symbol: update.Symbol, exchange: update.Exchange, interval: interval}
+		openTime := at.Truncate(Intervals[interval])
+
+		candle, ok := b.open[key]
+		if ok && openTime.Before(candle.OpenTime) {
+			continue
+		}
+
+		if !ok || openTime.After(candle.OpenTime) {
+			candle = &models.Candle{
+				Symbol:   update.Symbol,
+				Exchange: update.Exchange,
+				Interval: interval,
+				OpenTime: openTime,
+				Open:     update.Price,
+				High:     update.Price,
+				Low:      update.Price,
+			}
+			b.open[key] = candle
+		}
+
+		candle.High = max(candle.High, update.Price)
+		candle.Low = min(candle.Low, update.Price)
+		candle.Close = update.Price
+		candle.Volume += update.Size
+		if !consolidated {
+			candle.TradeCount++
+		}
+
+		b.dirty[candleKey{series: key, openTime: openTime.UnixNano()}] = candle
+	}
+}
+
+// Drain returns copies of every candle changed since the previous call,
+// oldest first
+func (b *Builder) Drain() []*models.Candle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	drained := make([]*models.Candle, 0, len(b.dirty))
+	for key, candle := range b.dirty {
+		c := *candle
+		drained = append(drained, &c)
+		delete(b.dirty, key)
+	}
+
+	sort.Slice(drained, func(i, j int) bool {
+		return drained[i].OpenTime.Before(drained[j].OpenTime)
+	})
+	return drained
+}
+
+// Restore seeds the open candle of a series, e.g. from the database after a
+// restart, so trades in the current interval extend it instead of
+// overwriting it
+func (b *Builder) Restore(candle *models.Candle) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := seriesKey{symbol: candle.Symbol, exchange: candle.Exchange, interval: candle.Interval}
+	if current, ok := b.open[key]; ok && !candle.OpenTime.After(current.OpenTime) {
+		return
+	}
+	c := *candle
+	b.open[key] = &c
+}
+
+// Requeue marks candles whose write failed as changed again so the next
+// Drain retries them. A candle still open is requeued with its live values.
+func (b *Builder) Requeue(failed []*models.Candle) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, candle := range failed {
+		series := seriesKey{symbol: candle.Symbol, exchange: candle.Exchange, interval: candle.Interval}
+		key := candleKey{series: series, openTime: candle.OpenTime.UnixNano()}
+		if _, ok := b.dirty[key]; ok {
+			continue
+		}
+		if open, ok := b.open[series]; ok && open.OpenTime.Equal(candle.OpenTime) {
+			b.dirty[key] = open
+			continue
+		}
+		b.dirty[key] = candle
+	}
+}
@@ -0,0 +1,142 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+var start = time.Date(2026, 3, 14, 23, 58, 30, 0, time.UTC)
+
+func trade(price, size float64) models.PriceUpdate {
+	return models.PriceUpdate{Exchange: "coinbase", Symbol: "BTC-USD", Price: price, Size: size}
+}
+
+// byInterval indexes drained candles by interval and open time
+func byInterval(candles []*models.Candle) map[string]map[time.Time]*models.Candle {
+	index := make(map[string]map[time.Time]*models.Candle)
+	for _, c := range candles {
+		if index[c.Interval] == nil {
+			index[c.Interval] = make(map[time.Time]*models.Candle)
+		}
+		index[c.Interval][c.OpenTime] = c
+	}
+	return index
+}
+
+func TestBuilderRollover(t *testing.T) {
+	b := NewBuilder([]string{"1m", "5m", "1d"})
+
+	// 23:58:30 and 23:58:50 fall in one minute, 00:00:10 the next day
+	b.Add(trade(100, 1), start)
+	b.Add(trade(105, 2), start.Add(20*time.Second))
+	b.Add(trade(95, 1), start.Add(25*time.Second))
+	b.Add(trade(101, 1), start.Add(100*time.Second))
+
+	candles := byInterval(b.Drain())
+	minute := time.Date(2026, 3, 14, 23, 58, 0, 0, time.UTC)
+	midnight := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		openTime time.Time
+		want     models.Candle
+	}{
+		{"1m", minute, models.Candle{Open: 100, High: 105, Low: 95, Close: 95, Volume: 4, TradeCount: 3}},
+		{"1m", midnight, models.Candle{Open: 101, High: 101, Low: 101, Close: 101, Volume: 1, TradeCount: 1}},
+		{"5m", time.Date(2026, 3, 14, 23, 55, 0, 0, time.UTC), models.Candle{Open: 100, High: 105, Low: 95, Close: 95, Volume: 4, TradeCount: 3}},
+		{"5m", midnight, models.Candle{Open: 101, High: 101, Low: 101, Close: 101, Volume: 1, TradeCount: 1}},
+		// Daily candles open at midnight UTC
+		{"1d", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC), models.Candle{Open: 100, High: 105, Low: 95, Close: 95, Volume: 4, TradeCount: 3}},
+		{"1d", midnight, models.Candle{Open: 101, High: 101, Low: 101, Close: 101, Volume: 1, TradeCount: 1}},
+	}
+
+	for _, tt := range tests {
+		got := candles[tt.interval][tt.openTime]
+		if got == nil {
+			t.Errorf("no %s candle at %s", tt.interval, tt.openTime)
+			continue
+		}
+		w := tt.want
+		if got.Open != w.Open || got.High != w.High || got.Low != w.Low || got.Close != w.Close ||
+			got.Volume != w.Volume || got.TradeCount != w.TradeCount {
+			t.Errorf("%s candle at %s = %+v, want %+v", tt.interval, tt.openTime, *got, w)
+		}
+	}
+	if n := len(candles["1m"]) + len(candles["5m"]) + len(candles["1d"]); n != len(tests) {
+		t.Errorf("drained %d candles, want %d", n, len(tests))
+	}
+}
+
+func TestBuilderLateAndInvalidTrades(t *testing.T) {
+	b := NewBuilder([]string{"1m"})
+	b.Add(trade(100, 1), start.Add(time.Minute))
+
+	// Older than the open candle, or without a price
+	b.Add(trade(50, 1), start)
+	b.Add(trade(0, 1), start.Add(time.Minute))
+
+	drained := b.Drain()
+	if len(drained) != 1 || drained[0].Low != 100 || drained[0].TradeCount != 1 {
+		t.Errorf("Drain = %+v, want one candle of the single valid trade", drained)
+	}
+	if drained := b.Drain(); len(drained) != 0 {
+		t.Errorf("second Drain = %+v, want nothing changed", drained)
+	}
+}
+
+func TestBuilderConsolidated(t *testing.T) {
+	b := NewBuilder([]string{"1m"})
+	b.Add(trade(100, 1), start)
+	b.Add(models.PriceUpdate{Exchange: models.ConsolidatedExchange, Symbol: "BTC-USD", Price: 101, Size: 3}, start)
+
+	for _, c := range b.Drain() {
+		switch c.Exchange {
+		case "coinbase":
+			if c.TradeCount != 1 {
+				t.Errorf("coinbase trade_count = %d, want 1", c.TradeCount)
+			}
+		case models.ConsolidatedExchange:
+			if c.TradeCount != 0 || c.Volume != 3 || c.Close != 101 {
+				t.Errorf("consolidated candle = %+v, want volume 3 and no trades", *c)
+			}
+		}
+	}
+}
+
+func TestBuilderRestoreAndRequeue(t *testing.T) {
+	b := NewBuilder([]string{"1m"})
+	openTime := start.Truncate(time.Minute)
+	b.Restore(&models.Candle{Symbol: "BTC-USD", Exchange: "coinbase", Interval: "1m", OpenTime: openTime,
+		Open: 90, High: 110, Low: 90, Close: 100, Volume: 5, TradeCount: 7})
+
+	// A trade in the restored minute extends it
+	b.Add(trade(120, 1), start.Add(10*time.Second))
+	drained := b.Drain()
+	if len(drained) != 1 {
+		t.Fatalf("Drain = %d candles, want 1", len(drained))
+	}
+	c := drained[0]
+	if c.Open != 90 || c.High != 120 || c.Low != 90 || c.Close != 120 || c.Volume != 6 || c.TradeCount != 8 {
+		t.Errorf("restored candle after a trade = %+v", *c)
+	}
+
+	// A failed write of the open candle is retried with its live values
+	b.Add(trade(80, 1), start.Add(20*time.Second))
+	b.Drain()
+	b.Requeue(drained)
+	retried := b.Drain()
+	if len(retried) != 1 || retried[0].Low != 80 || retried[0].TradeCount != 9 {
+		t.Errorf("requeued candle = %+v, want the live candle", retried)
+	}
+}
+
+func TestParseIntervals(t *testing.T) {
+	if _, err := ParseIntervals([]string{"1m", "1d"}); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseIntervals([]string{"1m", "2h"}); err == nil {
+		t.Error("ParseIntervals accepted 2h")
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// UpsertCandles writes candles, replacing any existing bar for the same
+// symbol, exchange, interval and open time
+func UpsertCandles(ctx context.Context, candles []*models.Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO candles (symbol, exchange, timeframe, open_time, open, high, low, close, volume, trade_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (symbol, exchange, timeframe, open_time) DO UPDATE
+		SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+			volume = EXCLUDED.volume, trade_count = EXCLUDED.trade_count
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range candles {
+		if _, err := stmt.ExecContext(ctx,
+			c.Symbol, c.Exchange, c.Interval, c.OpenTime,
+			c.Open, c.High, c.Low, c.Close, c.Volume, c.TradeCount,
+		); err != nil {
+			logger.Log.Error("Failed to upsert candle",
+				zap.String("symbol", c.Symbol),
+				zap.String("exchange", c.Exchange),
+				zap.String("interval", c.Interval),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetCandles retrieves the candles of one series with open time in [from, to)
+func GetCandles(ctx context.Context, symbol, exchange, interval string, from, to time.Time) ([]*models.Candle, error) {
+	query := `
+		SELECT symbol, exchange, timeframe, open_time, open, high, low, close, volume, trade_count
+		FROM candles
+		WHERE symbol = $1 AND exchange = $2 AND timeframe = $3 AND open_time >= $4 AND open_time < $5
+		ORDER BY open_time
+	`
+
+	return queryCandles(ctx, query, symbol, exchange, interval, from, to)
+}
+
+// GetCandlesOpenAt retrieves every candle of an interval that opened at
+// openTime, used to resume in-progress bars after a restart
+func GetCandlesOpenAt(ctx context.Context, interval string, openTime time.Time) ([]*models.Candle, error) {
+	query := `
+		SELECT symbol, exchange, timeframe, open_time, open, high, low, close, volume, trade_count
+		FROM candles
+		WHERE timeframe = $1 AND open_time = $2
+	`
+
+	return queryCandles(ctx, query, interval, openTime)
+}
+
+// queryCandles runs a candle query and scans the rows
+func queryCandles(ctx context.Context, query string, args ...interface{}) ([]*models.Candle, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("Failed to query candles", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candles []*models.Candle
+	for rows.Next() {
+		var c models.Candle
+		if err := rows.Scan(
+			&c.Symbol, &c.Exchange, &c.Interval, &c.OpenTime,
+			&c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.TradeCount,
+		); err != nil {
+			return nil, err
+		}
+		c.OpenTime = c.OpenTime.UTC()
+		candles = append(candles, &c)
+	}
+
+	return candles, rows.Err()
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// ErrContactNotFound is returned when a user has no address on a channel
+var ErrContactNotFound = errors.New("contact not found")
+
+// UpsertUserContact sets a user's address on a channel
+func UpsertUserContact(ctx context.Context, contact *models.UserContact) error {
+	query := `
+		INSERT INTO user_contacts (user_id, channel, address, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, channel) DO UPDATE
+		SET address = EXCLUDED.address, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := db.ExecContext(ctx, query, contact.UserID, contact.Channel, contact.Address, contact.UpdatedAt)
+	if err != nil {
+		logger.Log.Error("Failed to save user contact",
+			zap.String("user_id", contact.UserID),
+			zap.String("channel", contact.Channel),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// GetUserContact retrieves a user's address on one channel
+func GetUserContact(ctx context.Context, userID, channel string) (*models.UserContact, error) {
+	query := `
+		SELECT user_id, channel, address, updated_at
+		FROM user_contacts
+		WHERE user_id = $1 AND channel = $2
+	`
+
+	var c models.UserContact
+	err := db.QueryRowContext(ctx, query, userID, channel).Scan(&c.UserID, &c.Channel, &c.Address, &c.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrContactNotFound
+		}
+		logger.Log.Error("Failed to retrieve user contact",
+			zap.String("user_id", userID),
+			zap.String("channel", channel),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// GetUserContacts retrieves every address of a user
+func GetUserContacts(ctx context.Context, userID string) ([]*models.UserContact, error) {
+	query := `
+		SELECT user_id, channel, address, updated_at
+		FROM user_contacts
+		WHERE user_id = $1
+		ORDER BY channel
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.Log.Error("Failed to query user contacts",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []*models.UserContact{}
+	for rows.Next() {
+		var c models.UserContact
+		if err := rows.Scan(&c.UserID, &c.Channel, &c.Address, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, &c)
+	}
+
+	return contacts, rows.Err()
+}
+
+// DeleteUserContact removes a user's address on a channel
+func DeleteUserContact(ctx context.Context, userID, channel string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM user_contacts WHERE user_id = $1 AND channel = $2`, userID, channel)
+	if err != nil {
+		logger.Log.Error("Failed to delete user contact",
+			zap.String("user_id", userID),
+			zap.String("channel", channel),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrContactNotFound
+	}
+
+	return nil
+}
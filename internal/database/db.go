@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"pricenotification/internal/logger"
@@ -15,9 +17,25 @@ import (
 
 var db *sql.DB
 
+// alertColumns is the column list read by scanAlert
+const alertColumns = `id, user_id, symbol, COALESCE(exchange, 'consolidated'), type, upper_threshold, lower_threshold,
+	change_pct, window_minutes, direction, reference_price, trail_amount, formula, leg_symbol, leg_exchange, stale_seconds,
+	multiplier, baseline_minutes, min_size, trade_side, condition, candle_interval, on_close, hysteresis, sustain_seconds,
+	sustain_updates, mode, cooldown, max_triggers, trigger_count, status, expires_at, snoozed_until, escalation_id,
+	created_at, updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// connString is kept for opening dedicated LISTEN connections
+var connString string
+
 // InitDB initializes the database connection
 func InitDB(connStr string) error {
 	var err error
+	connString = connStr
 	db, err = sql.Open("postgres", connStr)
 	if err != nil {
 		return err
@@ -43,8 +61,13 @@ func InitDB(connStr string) error {
 // CreateAlert inserts a new alert into the database
 func CreateAlert(ctx context.Context, alert *models.Alert) error {
 	query := `
-		INSERT INTO alerts (id, user_id, symbol, upper_threshold, lower_threshold, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO alerts (id, user_id, symbol, exchange, type, upper_threshold, lower_threshold,
+			change_pct, window_minutes, direction, reference_price, trail_amount, formula, leg_symbol, leg_exchange,
+			stale_seconds, multiplier, baseline_minutes, min_size, trade_side, condition, candle_interval, on_close,
+			hysteresis, sustain_seconds, sustain_updates, mode, cooldown, max_triggers, trigger_count, status, expires_at,
+			escalation_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22,
+			$23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35)
 	`
 	
 	_, err := db.ExecContext(
@@ -53,8 +76,36 @@ func CreateAlert(ctx context.Context, alert *models.Alert) error {
 		alert.ID,
 		alert.UserID,
 		alert.Symbol,
+		alert.Exchange,
+		alert.Type,
 		alert.UpperThreshold,
 		alert.LowerThreshold,
+		alert.ChangePct,
+		alert.WindowMinutes,
+		alert.Direction,
+		alert.ReferencePrice,
+		alert.TrailAmount,
+		alert.Formula,
+		alert.LegSymbol,
+		alert.LegExchange,
+		alert.StaleSeconds,
+		alert.Multiplier,
+		alert.BaselineWindow,
+		alert.MinSize,
+		alert.TradeSide,
+		alert.Condition,
+		alert.Interval,
+		alert.OnClose,
+		alert.Hysteresis,
+		alert.SustainSeconds,
+		alert.SustainUpdates,
+		alert.Mode,
+		alert.Cooldown,
+		alert.MaxTriggers,
+		alert.TriggerCount,
+		alert.Status,
+		alert.ExpiresAt,
+		alert.EscalationID,
 		alert.CreatedAt,
 		alert.UpdatedAt,
 	)
@@ -67,30 +118,19 @@ func CreateAlert(ctx context.Context, alert *models.Alert) error {
 		return err
 	}
 	
+	notifyAlertChange(ctx, models.AlertCreated, alert.ID, alert.Symbol)
 	return nil
 }
 
 // GetAlertByID retrieves an alert by its ID
 func GetAlertByID(ctx context.Context, id string) (*models.Alert, error) {
 	query := `
-		SELECT id, user_id, symbol, upper_threshold, lower_threshold, created_at, updated_at
+		SELECT ` + alertColumns + `
 		FROM alerts
 		WHERE id = $1
 	`
 	
-	var alert models.Alert
-	var upperThreshold, lowerThreshold sql.NullFloat64
-	
-	err := db.QueryRowContext(ctx, query, id).Scan(
-		&alert.ID,
-		&alert.UserID,
-		&alert.Symbol,
-		&upperThreshold,
-		&lowerThreshold,
-		&alert.CreatedAt,
-		&alert.UpdatedAt,
-	)
-	
+	alert, err := scanAlert(db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors.New("alert not found")
@@ -102,24 +142,13 @@ func GetAlertByID(ctx context.Context, id string) (*models.Alert, error) {
 		return nil, err
 	}
 	
-	// Convert nullable fields
-	if upperThreshold.Valid {
-		val := upperThreshold.Float64
-		alert.UpperThreshold = &val
-	}
-	
-	if lowerThreshold.Valid {
-		val := lowerThreshold.Float64
-		alert.LowerThreshold = &val
-	}
-	
-	return &alert, nil
+	return alert, nil
 }
 
 // GetAlertsByUserID retrieves all alerts for a specific user
 func GetAlertsByUserID(ctx context.Context, userID string) ([]*models.Alert, error) {
 	query := `
-		SELECT id, user_id, symbol, upper_threshold, lower_threshold, created_at, updated_at
+		SELECT ` + alertColumns + `
 		FROM alerts
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -141,7 +170,7 @@ func GetAlertsByUserID(ctx context.Context, userID string) ([]*models.Alert, err
 // GetAlertsBySymbol retrieves all alerts for a specific crypto symbol
 func GetAlertsBySymbol(ctx context.Context, symbol string) ([]*models.Alert, error) {
 	query := `
-		SELECT id, user_id, symbol, upper_threshold, lower_threshold, created_at, updated_at
+		SELECT ` + alertColumns + `
 		FROM alerts
 		WHERE symbol = $1
 		ORDER BY created_at DESC
@@ -163,7 +192,7 @@ func GetAlertsBySymbol(ctx context.Context, symbol string) ([]*models.Alert, err
 // GetAllAlerts retrieves all alerts
 func GetAllAlerts(ctx context.Context) ([]*models.Alert, error) {
 	query := `
-		SELECT id, user_id, symbol, upper_threshold, lower_threshold, created_at, updated_at
+		SELECT ` + alertColumns + `
 		FROM alerts
 		ORDER BY created_at DESC
 	`
@@ -178,25 +207,68 @@ func GetAllAlerts(ctx context.Context) ([]*models.Alert, error) {
 	return scanAlerts(rows)
 }
 
-// UpdateAlert updates an existing alert
-func UpdateAlert(ctx context.Context, alert *models.Alert) error {
+// UpdateAlert updates an existing alert. A non-empty status moves the alert
+// to it in the same statement, ending any snooze; re-activating a triggered
+// alert resets its trigger count. The status, trigger count and snooze of
+// alert are refreshed from the stored row.
+func UpdateAlert(ctx context.Context, alert *models.Alert, status string) error {
 	query := `
 		UPDATE alerts
-		SET symbol = $1, upper_threshold = $2, lower_threshold = $3, updated_at = $4
-		WHERE id = $5
+		SET symbol = $1, exchange = $2, type = $3, upper_threshold = $4, lower_threshold = $5,
+			change_pct = $6, window_minutes = $7, direction = $8, reference_price = $9, trail_amount = $10,
+			formula = $11, leg_symbol = $12, leg_exchange = $13, stale_seconds = $14, multiplier = $15,
+			baseline_minutes = $16, min_size = $17, trade_side = $18, condition = $19, candle_interval = $20,
+			on_close = $21, hysteresis = $22, sustain_seconds = $23, sustain_updates = $24, mode = $25, cooldown = $26,
+			max_triggers = $27, expires_at = $28, escalation_id = $29, updated_at = $30,
+			status = CASE WHEN $32 <> '' THEN $32 ELSE status END,
+			trigger_count = CASE WHEN $32 = 'active' AND status = 'triggered' THEN 0 ELSE trigger_count END,
+			snoozed_until = CASE WHEN $32 <> '' AND $32 <> status THEN NULL ELSE snoozed_until END
+		WHERE id = $31
+		RETURNING status, trigger_count, snoozed_until
 	`
 	
-	_, err := db.ExecContext(
+	var snoozedUntil sql.NullTime
+	err := db.QueryRowContext(
 		ctx,
 		query,
 		alert.Symbol,
+		alert.Exchange,
+		alert.Type,
 		alert.UpperThreshold,
 		alert.LowerThreshold,
+		alert.ChangePct,
+		alert.WindowMinutes,
+		alert.Direction,
+		alert.ReferencePrice,
+		alert.TrailAmount,
+		alert.Formula,
+		alert.LegSymbol,
+		alert.LegExchange,
+		alert.StaleSeconds,
+		alert.Multiplier,
+		alert.BaselineWindow,
+		alert.MinSize,
+		alert.TradeSide,
+		alert.Condition,
+		alert.Interval,
+		alert.OnClose,
+		alert.Hysteresis,
+		alert.SustainSeconds,
+		alert.SustainUpdates,
+		alert.Mode,
+		alert.Cooldown,
+		alert.MaxTriggers,
+		alert.ExpiresAt,
+		alert.EscalationID,
 		alert.UpdatedAt,
 		alert.ID,
-	)
+		status,
+	).Scan(&alert.Status, &alert.TriggerCount, &snoozedUntil)
 	
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("alert not found")
+		}
 		logger.Log.Error("Failed to update alert", 
 			zap.String("alert_id", alert.ID),
 			zap.Error(err),
@@ -204,15 +276,145 @@ func UpdateAlert(ctx context.Context, alert *models.Alert) error {
 		return err
 	}
 	
+	alert.SnoozedUntil = nil
+	if snoozedUntil.Valid {
+		alert.SnoozedUntil = &snoozedUntil.Time
+	}
+	
+	notifyAlertChange(ctx, models.AlertUpdated, alert.ID, alert.Symbol)
 	return nil
 }
 
-// DeleteAlert deletes an alert by ID
+// AlertSelector picks the alerts a status change applies to: one alert by
+// ID, or the alerts of a user, optionally of one symbol
+type AlertSelector struct {
+	ID     string
+	UserID string
+	Symbol string
+}
+
+// where returns the selector as a condition on the arguments after args
+func (s AlertSelector) where(args []any) (string, []any, error) {
+	var conditions []string
+	add := func(column, value string) {
+		if value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	add("id", s.ID)
+	add("user_id", s.UserID)
+	add("symbol", s.Symbol)
+
+	if s.ID == "" && s.UserID == "" {
+		return "", nil, errors.New("alert selector needs an ID or a user ID")
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// PauseAlerts pauses the active and paused alerts of sel until the given
+// time, or until they are resumed when until is nil, and returns their IDs
+func PauseAlerts(ctx context.Context, sel AlertSelector, until *time.Time) ([]string, error) {
+	where, args, err := sel.where([]any{until, time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return changeAlertStatuses(ctx, `
+		UPDATE alerts
+		SET status = 'paused', snoozed_until = $1, updated_at = $2
+		WHERE status IN ('active', 'paused') AND `+where+`
+		RETURNING id, symbol
+	`, args...)
+}
+
+// ResumeAlerts resumes the paused alerts of sel and returns their IDs.
+// Alerts whose expiry passed while they were paused become expired.
+func ResumeAlerts(ctx context.Context, sel AlertSelector, now time.Time) ([]string, error) {
+	where, args, err := sel.where([]any{now})
+	if err != nil {
+		return nil, err
+	}
+	return changeAlertStatuses(ctx, `
+		UPDATE alerts
+		SET status = CASE WHEN expires_at <= $1 THEN 'expired' ELSE 'active' END,
+			snoozed_until = NULL,
+			updated_at = $1
+		WHERE status = 'paused' AND `+where+`
+		RETURNING id, symbol
+	`, args...)
+}
+
+// ResumeSnoozedAlerts resumes the paused alerts whose snooze ended at or
+// before now and returns how many there were
+func ResumeSnoozedAlerts(ctx context.Context, now time.Time) (int, error) {
+	resumed, err := changeAlertStatuses(ctx, `
+		UPDATE alerts
+		SET status = CASE WHEN expires_at <= $1 THEN 'expired' ELSE 'active' END,
+			snoozed_until = NULL,
+			updated_at = $1
+		WHERE status = 'paused' AND snoozed_until <= $1
+		RETURNING id, symbol
+	`, now)
+	return len(resumed), err
+}
+
+// ExpireAlerts moves the active alerts whose expiry is at or before now to
+// expired and returns how many there were
+func ExpireAlerts(ctx context.Context, now time.Time) (int, error) {
+	expired, err := changeAlertStatuses(ctx, `
+		UPDATE alerts
+		SET status = 'expired'
+		WHERE status = 'active' AND expires_at <= $1
+		RETURNING id, symbol
+	`, now)
+	return len(expired), err
+}
+
+// changeAlertStatuses runs an update of alert statuses returning the ID and
+// symbol of every changed alert, notifies the changes and returns the IDs
+func changeAlertStatuses(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("Failed to change alert statuses", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.AlertChange
+	for rows.Next() {
+		change := models.AlertChange{Op: models.AlertUpdated}
+		if err := rows.Scan(&change.AlertID, &change.Symbol); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(changes))
+	for _, change := range changes {
+		notifyAlertChange(ctx, change.Op, change.AlertID, change.Symbol)
+		ids = append(ids, change.AlertID)
+	}
+	return ids, nil
+}
+
+// DeleteAlert deletes an alert by ID and ends the escalation of its
+// firings, which outlive it in the history
 func DeleteAlert(ctx context.Context, id string) error {
-	query := `DELETE FROM alerts WHERE id = $1`
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 	
-	result, err := db.ExecContext(ctx, query, id)
+	var symbol string
+	err = tx.QueryRowContext(ctx, `DELETE FROM alerts WHERE id = $1 RETURNING symbol`, id).Scan(&symbol)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("alert not found")
+		}
 		logger.Log.Error("Failed to delete alert", 
 			zap.String("alert_id", id),
 			zap.Error(err),
@@ -220,52 +422,66 @@ func DeleteAlert(ctx context.Context, id string) error {
 		return err
 	}
 	
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE triggered_alerts
+		SET next_escalation_at = NULL,
+			delivery_status = CASE WHEN `+undelivered+` THEN 'suppressed' ELSE delivery_status END
+		WHERE alert_id = $1 AND next_escalation_at IS NOT NULL
+	`, id); err != nil {
+		logger.Log.Error("Failed to end escalations of deleted alert",
+			zap.String("alert_id", id),
+			zap.Error(err),
+		)
 		return err
 	}
 	
-	if rowsAffected == 0 {
-		return errors.New("alert not found")
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 	
+	notifyAlertChange(ctx, models.AlertDeleted, id, symbol)
 	return nil
 }
 
+// GetAlertSymbols returns the distinct symbols that have at least one alert,
+// including the second legs of spread and ratio alerts
+func GetAlertSymbols(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT symbol FROM alerts
+		UNION
+		SELECT leg_symbol FROM alerts WHERE leg_symbol <> ''
+		ORDER BY 1
+	`
+	
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Log.Error("Failed to query alert symbols", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	
+	return symbols, rows.Err()
+}
+
 // Helper function to scan alert rows
 func scanAlerts(rows *sql.Rows) ([]*models.Alert, error) {
 	var alerts []*models.Alert
 	
 	for rows.Next() {
-		var alert models.Alert
-		var upperThreshold, lowerThreshold sql.NullFloat64
-		
-		err := rows.Scan(
-			&alert.ID,
-			&alert.UserID,
-			&alert.Symbol,
-			&upperThreshold,
-			&lowerThreshold,
-			&alert.CreatedAt,
-			&alert.UpdatedAt,
-		)
-		
+		alert, err := scanAlert(rows)
 		if err != nil {
 			return nil, err
 		}
-		
-		// Convert nullable fields
-		if upperThreshold.Valid {
-			val := upperThreshold.Float64
-			alert.UpperThreshold = &val
-		}
-		
-		if lowerThreshold.Valid {
-			val := lowerThreshold.Float64
-			alert.LowerThreshold = &val
-		}
-		
-		alerts = append(alerts, &alert)
+		alerts = append(alerts, alert)
 	}
 	
 	if err := rows.Err(); err != nil {
@@ -275,3 +491,99 @@ func scanAlerts(rows *sql.Rows) ([]*models.Alert, error) {
 	return alerts, nil
 }
 
+// scanAlert reads one row selected with alertColumns
+func scanAlert(row rowScanner) (*models.Alert, error) {
+	var alert models.Alert
+	var upperThreshold, lowerThreshold, changePct, referencePrice, trailAmount, multiplier, minSize sql.NullFloat64
+	var expiresAt, snoozedUntil sql.NullTime
+
+	err := row.Scan(
+		&alert.ID,
+		&alert.UserID,
+		&alert.Symbol,
+		&alert.Exchange,
+		&alert.Type,
+		&upperThreshold,
+		&lowerThreshold,
+		&changePct,
+		&alert.WindowMinutes,
+		&alert.Direction,
+		&referencePrice,
+		&trailAmount,
+		&alert.Formula,
+		&alert.LegSymbol,
+		&alert.LegExchange,
+		&alert.StaleSeconds,
+		&multiplier,
+		&alert.BaselineWindow,
+		&minSize,
+		&alert.TradeSide,
+		&alert.Condition,
+		&alert.Interval,
+		&alert.OnClose,
+		&alert.Hysteresis,
+		&alert.SustainSeconds,
+		&alert.SustainUpdates,
+		&alert.Mode,
+		&alert.Cooldown,
+		&alert.MaxTriggers,
+		&alert.TriggerCount,
+		&alert.Status,
+		&expiresAt,
+		&snoozedUntil,
+		&alert.EscalationID,
+		&alert.CreatedAt,
+		&alert.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert nullable fields
+	if upperThreshold.Valid {
+		val := upperThreshold.Float64
+		alert.UpperThreshold = &val
+	}
+
+	if lowerThreshold.Valid {
+		val := lowerThreshold.Float64
+		alert.LowerThreshold = &val
+	}
+
+	if changePct.Valid {
+		val := changePct.Float64
+		alert.ChangePct = &val
+	}
+
+	if referencePrice.Valid {
+		val := referencePrice.Float64
+		alert.ReferencePrice = &val
+	}
+
+	if trailAmount.Valid {
+		val := trailAmount.Float64
+		alert.TrailAmount = &val
+	}
+
+	if multiplier.Valid {
+		val := multiplier.Float64
+		alert.Multiplier = &val
+	}
+
+	if minSize.Valid {
+		val := minSize.Float64
+		alert.MinSize = &val
+	}
+
+	if expiresAt.Valid {
+		val := expiresAt.Time
+		alert.ExpiresAt = &val
+	}
+
+	if snoozedUntil.Valid {
+		val := snoozedUntil.Time
+		alert.SnoozedUntil = &val
+	}
+
+	return &alert, nil
+}
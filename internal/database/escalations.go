@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrEscalationPolicyNotFound is returned for an unknown policy
+	ErrEscalationPolicyNotFound = errors.New("escalation policy not found")
+	// ErrEscalationPolicyInUse is returned when deleting a policy an alert
+	// still escalates with
+	ErrEscalationPolicyInUse = errors.New("escalation policy is used by an alert")
+)
+
+// CreateEscalationPolicy stores an escalation policy
+func CreateEscalationPolicy(ctx context.Context, policy *models.EscalationPolicy) error {
+	steps, err := json.Marshal(policy.Steps)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO escalation_policies (id, user_id, name, steps, repeat_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = db.ExecContext(ctx, query, policy.ID, policy.UserID, policy.Name, steps, policy.RepeatSeconds, policy.CreatedAt)
+	if err != nil {
+		logger.Log.Error("Failed to create escalation policy",
+			zap.String("user_id", policy.UserID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// GetEscalationPoliciesByUserID retrieves a user's escalation policies
+func GetEscalationPoliciesByUserID(ctx context.Context, userID string) ([]*models.EscalationPolicy, error) {
+	query := `
+		SELECT id, user_id, name, steps, repeat_seconds, created_at
+		FROM escalation_policies
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.Log.Error("Failed to query escalation policies",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []*models.EscalationPolicy{}
+	for rows.Next() {
+		policy, err := scanEscalationPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+// GetEscalationPolicyByID retrieves one escalation policy
+func GetEscalationPolicyByID(ctx context.Context, id string) (*models.EscalationPolicy, error) {
+	query := `
+		SELECT id, user_id, name, steps, repeat_seconds, created_at
+		FROM escalation_policies
+		WHERE id = $1
+	`
+
+	policy, err := scanEscalationPolicy(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEscalationPolicyNotFound
+		}
+		logger.Log.Error("Failed to retrieve escalation policy",
+			zap.String("escalation_id", id),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// DeleteEscalationPolicy removes one of a user's escalation policies, unless
+// an alert still uses it. The check and the delete are one statement, so an
+// alert cannot start using the policy in between.
+func DeleteEscalationPolicy(ctx context.Context, userID, id string) error {
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM escalation_policies
+		WHERE id = $1 AND user_id = $2
+			AND NOT EXISTS (SELECT 1 FROM alerts WHERE escalation_id = $1)
+	`, id, userID)
+	if err != nil {
+		logger.Log.Error("Failed to delete escalation policy",
+			zap.String("escalation_id", id),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	// Nothing was deleted: tell an unknown policy, or another user's, from
+	// one in use
+	var exists bool
+	if err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM escalation_policies WHERE id = $1 AND user_id = $2)
+	`, id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrEscalationPolicyInUse
+	}
+	return ErrEscalationPolicyNotFound
+}
+
+// undelivered holds for a triggered_alerts row without any outbox entry
+const undelivered = `NOT EXISTS (SELECT 1 FROM notification_outbox WHERE triggered_alert_id = triggered_alerts.id)`
+
+// ClaimDueEscalations leases up to limit firings whose next escalation step
+// is due, like ClaimOutboxEntries: the lease pushes next_escalation_at out,
+// so a firing whose dispatcher dies is escalated again once it expires
+func ClaimDueEscalations(ctx context.Context, limit int, lease time.Duration) ([]*models.TriggeredAlert, error) {
+	query := `
+		UPDATE triggered_alerts
+		SET next_escalation_at = now() + $2 * interval '1 second'
+		WHERE id IN (
+			SELECT id FROM triggered_alerts
+			WHERE next_escalation_at <= now()
+			ORDER BY next_escalation_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + triggeredColumns
+
+	rows, err := db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		logger.Log.Error("Failed to claim escalations", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTriggeredAlerts(rows)
+}
+
+// AdvanceEscalation records the steps of a claimed firing sent so far and
+// when the next is due, together with the outbox entries of the new steps.
+// A firing whose escalation ends without a single delivery is suppressed.
+// Nothing is recorded once the firing was acknowledged since it was claimed.
+func AdvanceEscalation(ctx context.Context, t *models.TriggeredAlert, outbox []*models.OutboxEntry) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// New entries make the firing pending again until they are delivered
+	result, err := tx.ExecContext(ctx, `
+		UPDATE triggered_alerts
+		SET escalation_step = $1, next_escalation_at = $2,
+			delivery_status = CASE
+				WHEN $3 THEN 'pending'
+				WHEN $2::timestamptz IS NULL AND `+undelivered+` THEN 'suppressed'
+				ELSE delivery_status
+			END
+		WHERE id = $4 AND acknowledged_at IS NULL
+	`, t.EscalationStep, t.NextEscalationAt, len(outbox) > 0, t.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+
+	if err := insertOutboxEntries(ctx, tx, outbox); err != nil {
+		logger.Log.Error("Failed to enqueue escalation",
+			zap.String("triggered_alert_id", t.ID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AcknowledgeAlert acknowledges every escalating firing of an alert owned by
+// userID, which stops their escalation, and returns how many it acknowledged.
+// The firings are matched on their own user_id, so those of a deleted alert
+// can still be acknowledged. Firings acknowledged before their first step
+// was sent are suppressed.
+func AcknowledgeAlert(ctx context.Context, alertID, userID string, at time.Time) (int, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE triggered_alerts
+		SET acknowledged_at = $1, acknowledged_by = $2, next_escalation_at = NULL,
+			delivery_status = CASE WHEN `+undelivered+` THEN 'suppressed' ELSE delivery_status END
+		WHERE alert_id = $3 AND user_id = $2 AND escalation_id <> '' AND acknowledged_at IS NULL
+	`, at, userID, alertID)
+	if err != nil {
+		logger.Log.Error("Failed to acknowledge alert",
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// scanEscalationPolicy reads one escalation_policies row
+func scanEscalationPolicy(row rowScanner) (*models.EscalationPolicy, error) {
+	var policy models.EscalationPolicy
+	var steps []byte
+	if err := row.Scan(&policy.ID, &policy.UserID, &policy.Name, &steps, &policy.RepeatSeconds, &policy.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(steps, &policy.Steps); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
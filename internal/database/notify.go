@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// alertChangesChannel is the Postgres NOTIFY channel carrying alert writes
+const alertChangesChannel = "alert_changes"
+
+// notifyAlertChange broadcasts an alert write to every LISTENing service.
+// Failures are logged only: listeners periodically resync from the table.
+func notifyAlertChange(ctx context.Context, op, alertID, symbol string) {
+	payload, err := json.Marshal(models.AlertChange{Op: op, AlertID: alertID, Symbol: symbol})
+	if err != nil {
+		logger.Log.Error("Failed to marshal alert change", zap.Error(err))
+		return
+	}
+
+	if _, err := db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, alertChangesChannel, string(payload)); err != nil {
+		logger.Log.Warn("Failed to notify alert change",
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+	}
+}
+
+// ListenAlertChanges calls handler for every alert write until ctx is
+// cancelled. After the listener reconnects, notifications may have been
+// missed, so handler is called with nil to signal that a full resync is due.
+func ListenAlertChanges(ctx context.Context, handler func(change *models.AlertChange)) error {
+	listener := pq.NewListener(connString, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Log.Warn("Alert change listener event", zap.Error(err))
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(alertChangesChannel); err != nil {
+		return err
+	}
+	logger.Log.Info("Listening for alert changes", zap.String("channel", alertChangesChannel))
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			if n == nil {
+				handler(nil)
+				continue
+			}
+			var change models.AlertChange
+			if err := json.Unmarshal([]byte(n.Extra), &change); err != nil {
+				logger.Log.Warn("Invalid alert change payload", zap.Error(err))
+				continue
+			}
+			handler(&change)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// insertOutboxEntries writes outbox entries inside tx
+func insertOutboxEntries(ctx context.Context, tx *sql.Tx, entries []*models.OutboxEntry) error {
+	query := `
+		INSERT INTO notification_outbox (id, triggered_alert_id, channel, target, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	for _, e := range entries {
+		if _, err := tx.ExecContext(ctx, query,
+			e.ID, e.TriggeredAlertID, e.Channel, e.Target, e.Status, e.Attempts, e.NextAttemptAt, e.CreatedAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClaimOutboxEntries leases up to limit due entries to the caller. Claiming
+// counts as an attempt and pushes next_attempt_at out by lease, so an entry
+// whose dispatcher dies mid-delivery is picked up again once the lease
+// expires. SKIP LOCKED lets several dispatchers claim concurrently.
+func ClaimOutboxEntries(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEntry, error) {
+	query := `
+		UPDATE notification_outbox
+		SET attempts = attempts + 1, next_attempt_at = now() + $2 * interval '1 second'
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, triggered_alert_id, channel, target, status, attempts, next_attempt_at, last_error, created_at
+	`
+
+	rows, err := db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		logger.Log.Error("Failed to claim outbox entries", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanOutboxEntries(rows)
+}
+
+// CompleteOutboxEntry records the outcome of a delivery attempt. A nil
+// deliveryErr marks the entry delivered; otherwise it is rescheduled at
+// retryAt, or marked dead when retryAt is nil. The triggered alert's overall
+// delivery status is refreshed in the same transaction.
+func CompleteOutboxEntry(ctx context.Context, entry *models.OutboxEntry, deliveryErr error, retryAt *time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	errMsg := ""
+	if deliveryErr != nil {
+		errMsg = deliveryErr.Error()
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO delivery_attempts (outbox_id, attempt, success, error, attempted_at)
+		VALUES ($1, $2, $3, $4, now())
+	`, entry.ID, entry.Attempts, deliveryErr == nil, errMsg); err != nil {
+		return err
+	}
+
+	status := models.OutboxDelivered
+	nextAttempt := time.Now()
+	switch {
+	case deliveryErr == nil:
+	case retryAt != nil:
+		status = models.OutboxPending
+		nextAttempt = *retryAt
+	default:
+		status = models.OutboxDead
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE notification_outbox
+		SET status = $1, next_attempt_at = $2, last_error = $3
+		WHERE id = $4
+	`, status, nextAttempt, errMsg, entry.ID); err != nil {
+		return err
+	}
+
+	// Failed once any channel is dead, delivered once every channel is
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE triggered_alerts
+		SET delivery_status = CASE
+			WHEN EXISTS (SELECT 1 FROM notification_outbox WHERE triggered_alert_id = $1 AND status = 'dead') THEN 'failed'
+			WHEN NOT EXISTS (SELECT 1 FROM notification_outbox WHERE triggered_alert_id = $1 AND status <> 'delivered') THEN 'delivered'
+			ELSE 'pending'
+		END
+		WHERE id = $1
+	`, entry.TriggeredAlertID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("Failed to complete outbox entry",
+			zap.String("outbox_id", entry.ID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// GetOutboxEntries retrieves the per-channel deliveries of a triggered alert
+func GetOutboxEntries(ctx context.Context, triggeredAlertID string) ([]*models.OutboxEntry, error) {
+	query := `
+		SELECT id, triggered_alert_id, channel, target, status, attempts, next_attempt_at, last_error, created_at
+		FROM notification_outbox
+		WHERE triggered_alert_id = $1
+		ORDER BY created_at, channel
+	`
+
+	rows, err := db.QueryContext(ctx, query, triggeredAlertID)
+	if err != nil {
+		logger.Log.Error("Failed to query outbox entries",
+			zap.String("triggered_alert_id", triggeredAlertID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanOutboxEntries(rows)
+}
+
+// GetDeliveryAttempts retrieves every delivery attempt of a triggered alert
+func GetDeliveryAttempts(ctx context.Context, triggeredAlertID string) ([]*models.DeliveryAttempt, error) {
+	query := `
+		SELECT a.outbox_id, a.attempt, a.success, a.error, a.attempted_at
+		FROM delivery_attempts a
+		JOIN notification_outbox o ON o.id = a.outbox_id
+		WHERE o.triggered_alert_id = $1
+		ORDER BY a.attempted_at
+	`
+
+	rows, err := db.QueryContext(ctx, query, triggeredAlertID)
+	if err != nil {
+		logger.Log.Error("Failed to query delivery attempts",
+			zap.String("triggered_alert_id", triggeredAlertID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*models.DeliveryAttempt{}
+	for rows.Next() {
+		var a models.DeliveryAttempt
+		if err := rows.Scan(&a.OutboxID, &a.Attempt, &a.Success, &a.Error, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &a)
+	}
+
+	return attempts, rows.Err()
+}
+
+// Helper function to scan outbox rows
+func scanOutboxEntries(rows *sql.Rows) ([]*models.OutboxEntry, error) {
+	entries := []*models.OutboxEntry{}
+
+	for rows.Next() {
+		var e models.OutboxEntry
+		if err := rows.Scan(
+			&e.ID, &e.TriggeredAlertID, &e.Channel, &e.Target, &e.Status,
+			&e.Attempts, &e.NextAttemptAt, &e.LastError, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	return entries, rows.Err()
+}
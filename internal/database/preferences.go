@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// ErrPreferencesNotFound is returned when a user never saved preferences
+var ErrPreferencesNotFound = errors.New("preferences not found")
+
+// GetNotificationPreferences retrieves a user's preferences together with
+// their contact addresses
+func GetNotificationPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error) {
+	query := `
+		SELECT user_id, channels, symbol_overrides, quiet_start, quiet_end, timezone, max_per_hour, updated_at
+		FROM notification_preferences
+		WHERE user_id = $1
+	`
+
+	var (
+		prefs                      models.NotificationPreferences
+		overrides                  []byte
+		quietStart, quietEnd, zone sql.NullString
+	)
+	err := db.QueryRowContext(ctx, query, userID).Scan(
+		&prefs.UserID, pq.Array(&prefs.Channels), &overrides,
+		&quietStart, &quietEnd, &zone, &prefs.MaxPerHour, &prefs.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPreferencesNotFound
+		}
+		logger.Log.Error("Failed to retrieve notification preferences",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	if err := json.Unmarshal(overrides, &prefs.SymbolOverrides); err != nil {
+		return nil, err
+	}
+	if quietStart.Valid && quietEnd.Valid {
+		prefs.QuietHours = &models.QuietHours{Start: quietStart.String, End: quietEnd.String, Timezone: zone.String}
+	}
+
+	contacts, err := GetUserContacts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs.Addresses = make(map[string]string, len(contacts))
+	for _, c := range contacts {
+		prefs.Addresses[c.Channel] = c.Address
+	}
+
+	return &prefs, nil
+}
+
+// UpsertNotificationPreferences saves a user's preferences and the
+// addresses they carry in one transaction. Addresses not mentioned are kept.
+func UpsertNotificationPreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	overrides, err := json.Marshal(prefs.SymbolOverrides)
+	if err != nil {
+		return err
+	}
+	if prefs.SymbolOverrides == nil {
+		overrides = []byte("{}")
+	}
+
+	var quietStart, quietEnd, zone sql.NullString
+	if q := prefs.QuietHours; q != nil {
+		quietStart = sql.NullString{String: q.Start, Valid: true}
+		quietEnd = sql.NullString{String: q.End, Valid: true}
+		zone = sql.NullString{String: q.Timezone, Valid: true}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notification_preferences (user_id, channels, symbol_overrides, quiet_start, quiet_end, timezone, max_per_hour, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE
+		SET channels = EXCLUDED.channels, symbol_overrides = EXCLUDED.symbol_overrides,
+			quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end, timezone = EXCLUDED.timezone,
+			max_per_hour = EXCLUDED.max_per_hour, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err = tx.ExecContext(ctx, query,
+		prefs.UserID, pq.Array(prefs.Channels), overrides,
+		quietStart, quietEnd, zone, prefs.MaxPerHour, prefs.UpdatedAt,
+	)
+	if err != nil {
+		logger.Log.Error("Failed to save notification preferences",
+			zap.String("user_id", prefs.UserID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	for channel, address := range prefs.Addresses {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_contacts (user_id, channel, address, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, channel) DO UPDATE
+			SET address = EXCLUDED.address, updated_at = EXCLUDED.updated_at
+		`, prefs.UserID, channel, address, prefs.UpdatedAt)
+		if err != nil {
+			logger.Log.Error("Failed to save user contact",
+				zap.String("user_id", prefs.UserID),
+				zap.String("channel", channel),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pricenotification/internal/candles"
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// ErrPriceNotFound is returned when no price is stored for a series
+var ErrPriceNotFound = errors.New("price not found")
+
+// candleFallback lists candle intervals consulted, finest first, once raw
+// history for a point in time has been deleted by retention
+var candleFallback = []string{"1m", "5m", "1h", "1d"}
+
+// InsertPriceHistory bulk-loads price points with COPY
+func InsertPriceHistory(ctx context.Context, points []*models.PricePoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("price_history", "symbol", "exchange", "price", "size", "traded_at"))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range points {
+		if _, err := stmt.ExecContext(ctx, p.Symbol, p.Exchange, p.Price, p.Size, p.Time); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// Flush the COPY buffer
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		logger.Log.Error("Failed to insert price history", zap.Int("points", len(points)), zap.Error(err))
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPriceAt returns the last price of a series at or before at. Raw history
+// is preferred; past its retention the finest covering candle gives the
+// price instead.
+func GetPriceAt(ctx context.Context, symbol, exchange string, at time.Time) (*models.PricePoint, error) {
+	query := `
+		SELECT symbol, exchange, price, size, traded_at
+		FROM price_history
+		WHERE symbol = $1 AND exchange = $2 AND traded_at <= $3
+		ORDER BY traded_at DESC
+		LIMIT 1
+	`
+
+	var p models.PricePoint
+	err := db.QueryRowContext(ctx, query, symbol, exchange, at).Scan(&p.Symbol, &p.Exchange, &p.Price, &p.Size, &p.Time)
+	if err == nil {
+		p.Time = p.Time.UTC()
+		return &p, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logger.Log.Error("Failed to query price",
+			zap.String("symbol", symbol),
+			zap.String("exchange", exchange),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return getCandlePriceAt(ctx, symbol, exchange, at)
+}
+
+// getCandlePriceAt returns the price at at from the finest candle that
+// opened at or before it: its close once it ended by at, its open while at
+// falls inside it, as the close is a later price
+func getCandlePriceAt(ctx context.Context, symbol, exchange string, at time.Time) (*models.PricePoint, error) {
+	query := `
+		SELECT open, close, open_time
+		FROM candles
+		WHERE symbol = $1 AND exchange = $2 AND timeframe = $3 AND open_time <= $4
+		ORDER BY open_time DESC
+		LIMIT 1
+	`
+
+	for _, interval := range candleFallback {
+		p := models.PricePoint{Symbol: symbol, Exchange: exchange}
+		var opening, closing float64
+		err := db.QueryRowContext(ctx, query, symbol, exchange, interval, at).Scan(&opening, &closing, &p.Time)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			logger.Log.Error("Failed to query candle close",
+				zap.String("symbol", symbol),
+				zap.String("exchange", exchange),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		p.Price = opening
+		if end := p.Time.Add(candles.Intervals[interval]); !end.After(at) {
+			p.Price, p.Time = closing, end
+		}
+		p.Time = p.Time.UTC()
+		return &p, nil
+	}
+
+	return nil, ErrPriceNotFound
+}
+
+// GetPriceHistory retrieves raw price points of a series in [from, to),
+// oldest first, up to limit rows
+func GetPriceHistory(ctx context.Context, symbol, exchange string, from, to time.Time, limit int) ([]*models.PricePoint, error) {
+	query := `
+		SELECT symbol, exchange, price, size, traded_at
+		FROM price_history
+		WHERE symbol = $1 AND exchange = $2 AND traded_at >= $3 AND traded_at < $4
+		ORDER BY traded_at
+		LIMIT $5
+	`
+
+	rows, err := db.QueryContext(ctx, query, symbol, exchange, from, to, limit)
+	if err != nil {
+		logger.Log.Error("Failed to query price history",
+			zap.String("symbol", symbol),
+			zap.String("exchange", exchange),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []*models.PricePoint
+	for rows.Next() {
+		var p models.PricePoint
+		if err := rows.Scan(&p.Symbol, &p.Exchange, &p.Price, &p.Size, &p.Time); err != nil {
+			return nil, err
+		}
+		p.Time = p.Time.UTC()
+		points = append(points, &p)
+	}
+
+	return points, rows.Err()
+}
+
+// retentionBatchSize bounds each retention DELETE so it never holds locks
+// on a large share of the table
+const retentionBatchSize = 10000
+
+// DeletePriceHistoryBefore removes raw price points older than cutoff and
+// returns how many rows were deleted
+func DeletePriceHistoryBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return deleteInBatches(ctx, `
+		DELETE FROM price_history
+		WHERE ctid IN (SELECT ctid FROM price_history WHERE traded_at < $1 LIMIT $2)
+	`, cutoff)
+}
+
+// DeleteCandlesBefore removes candles of one interval that opened before
+// cutoff and returns how many rows were deleted
+func DeleteCandlesBefore(ctx context.Context, interval string, cutoff time.Time) (int64, error) {
+	return deleteInBatches(ctx, `
+		DELETE FROM candles
+		WHERE ctid IN (SELECT ctid FROM candles WHERE open_time < $1 AND timeframe = $3 LIMIT $2)
+	`, cutoff, interval)
+}
+
+// deleteInBatches repeats a batched DELETE until it affects no rows. The
+// query receives the cutoff as $1 and the batch size as $2.
+func deleteInBatches(ctx context.Context, query string, cutoff time.Time, extra ...interface{}) (int64, error) {
+	args := append([]interface{}{cutoff, retentionBatchSize}, extra...)
+
+	var total int64
+	for {
+		result, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			logger.Log.Error("Failed to apply retention", zap.Error(err))
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < retentionBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// triggeredColumns is the column list read by scanTriggeredAlerts
+const triggeredColumns = `id, alert_id, user_id, symbol, exchange, price, threshold, direction, size, triggered_at,
+	delivery_status, escalation_id, escalation_step, next_escalation_at, acknowledged_at, acknowledged_by`
+
+// ErrAlertInactive is returned when a firing is recorded for an alert that
+// is no longer active, e.g. a one-shot alert another replica just fired
+var ErrAlertInactive = errors.New("alert is not active")
+
+// CreateTriggeredAlert records an alert firing together with its outbox
+// entries in one transaction, so every recorded firing is delivered. The
+// firing counts against the alert's lifecycle in the same transaction, so
+// once and times alerts fire exactly as often as they may across replicas.
+func CreateTriggeredAlert(ctx context.Context, t *models.TriggeredAlert, outbox []*models.OutboxEntry) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The row lock serializes concurrent firings of one alert: the second
+	// sees the status the first one left
+	var symbol, status string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE alerts
+		SET trigger_count = trigger_count + 1,
+			status = CASE
+				WHEN mode = 'once' OR (mode = 'times' AND trigger_count + 1 >= max_triggers) THEN 'triggered'
+				ELSE status
+			END
+		WHERE id = $1 AND status = 'active' AND (expires_at IS NULL OR expires_at > $2)
+		RETURNING symbol, status
+	`, t.AlertID, t.TriggeredAt).Scan(&symbol, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAlertInactive
+	}
+	if err != nil {
+		logger.Log.Error("Failed to count alert firing",
+			zap.String("alert_id", t.AlertID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	query := `
+		INSERT INTO triggered_alerts (id, alert_id, user_id, symbol, exchange, price, threshold, direction, size, triggered_at,
+			delivery_status, escalation_id, next_escalation_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	_, err = tx.ExecContext(ctx, query,
+		t.ID, t.AlertID, t.UserID, t.Symbol, t.Exchange,
+		t.Price, t.Threshold, t.Direction, t.Size, t.TriggeredAt, t.DeliveryStatus,
+		t.EscalationID, t.NextEscalationAt,
+	)
+	if err != nil {
+		logger.Log.Error("Failed to record triggered alert",
+			zap.String("alert_id", t.AlertID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := insertOutboxEntries(ctx, tx, outbox); err != nil {
+		logger.Log.Error("Failed to enqueue notifications",
+			zap.String("alert_id", t.AlertID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if status != models.StatusActive {
+		notifyAlertChange(ctx, models.AlertUpdated, t.AlertID, symbol)
+	}
+	return nil
+}
+
+// GetTriggeredAlertByID retrieves a single firing
+func GetTriggeredAlertByID(ctx context.Context, id string) (*models.TriggeredAlert, error) {
+	query := `
+		SELECT ` + triggeredColumns + `
+		FROM triggered_alerts
+		WHERE id = $1
+	`
+
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		logger.Log.Error("Failed to retrieve triggered alert",
+			zap.String("triggered_alert_id", id),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggered, err := scanTriggeredAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(triggered) == 0 {
+		return nil, errors.New("triggered alert not found")
+	}
+
+	return triggered[0], nil
+}
+
+// GetTriggeredAlertsByAlertID retrieves the most recent firings of an alert
+func GetTriggeredAlertsByAlertID(ctx context.Context, alertID string, limit int) ([]*models.TriggeredAlert, error) {
+	query := `
+		SELECT ` + triggeredColumns + `
+		FROM triggered_alerts
+		WHERE alert_id = $1
+		ORDER BY triggered_at DESC
+		LIMIT $2
+	`
+
+	rows, err := db.QueryContext(ctx, query, alertID, limit)
+	if err != nil {
+		logger.Log.Error("Failed to query alert history",
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTriggeredAlerts(rows)
+}
+
+// GetTriggeredAlertsByUserID retrieves a user's most recent notifications
+// triggered at or after since
+func GetTriggeredAlertsByUserID(ctx context.Context, userID string, since time.Time, limit int) ([]*models.TriggeredAlert, error) {
+	query := `
+		SELECT ` + triggeredColumns + `
+		FROM triggered_alerts
+		WHERE user_id = $1 AND triggered_at >= $2
+		ORDER BY triggered_at DESC
+		LIMIT $3
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID, since, limit)
+	if err != nil {
+		logger.Log.Error("Failed to query user notifications",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTriggeredAlerts(rows)
+}
+
+// Helper function to scan triggered alert rows
+func scanTriggeredAlerts(rows *sql.Rows) ([]*models.TriggeredAlert, error) {
+	triggered := []*models.TriggeredAlert{}
+
+	for rows.Next() {
+		var t models.TriggeredAlert
+		var nextEscalationAt, acknowledgedAt sql.NullTime
+		if err := rows.Scan(
+			&t.ID, &t.AlertID, &t.UserID, &t.Symbol, &t.Exchange,
+			&t.Price, &t.Threshold, &t.Direction, &t.Size, &t.TriggeredAt, &t.DeliveryStatus,
+			&t.EscalationID, &t.EscalationStep, &nextEscalationAt, &acknowledgedAt, &t.AcknowledgedBy,
+		); err != nil {
+			return nil, err
+		}
+		if nextEscalationAt.Valid {
+			t.NextEscalationAt = &nextEscalationAt.Time
+		}
+		if acknowledgedAt.Valid {
+			t.AcknowledgedAt = &acknowledgedAt.Time
+		}
+		triggered = append(triggered, &t)
+	}
+
+	return triggered, rows.Err()
+}
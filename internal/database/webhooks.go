@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// CreateWebhook registers a webhook endpoint
+func CreateWebhook(ctx context.Context, hook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, user_id, url, secret, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := db.ExecContext(ctx, query, hook.ID, hook.UserID, hook.URL, hook.Secret, hook.Active, hook.CreatedAt)
+	if err != nil {
+		logger.Log.Error("Failed to create webhook",
+			zap.String("user_id", hook.UserID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhooksByUserID retrieves a user's webhooks, including their secrets
+func GetWebhooksByUserID(ctx context.Context, userID string) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, secret, active, created_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.Log.Error("Failed to query webhooks",
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*models.Webhook{}
+	for rows.Next() {
+		var hook models.Webhook
+		if err := rows.Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Secret, &hook.Active, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook removes one of a user's webhooks
+func DeleteWebhook(ctx context.Context, userID, id string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		logger.Log.Error("Failed to delete webhook",
+			zap.String("webhook_id", id),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("webhook not found")
+	}
+
+	return nil
+}
+
+// CreateWebhookDeadLetter stores a delivery that exhausted its retries
+func CreateWebhookDeadLetter(ctx context.Context, dl *models.WebhookDeadLetter) error {
+	query := `
+		INSERT INTO webhook_dead_letters (id, webhook_id, triggered_alert_id, payload, attempts, last_error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := db.ExecContext(ctx, query, dl.ID, dl.WebhookID, dl.TriggeredAlertID, dl.Payload, dl.Attempts, dl.LastError, dl.CreatedAt)
+	if err != nil {
+		logger.Log.Error("Failed to store webhook dead letter",
+			zap.String("webhook_id", dl.WebhookID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhookByID retrieves a webhook, including its secret
+func GetWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, secret, active, created_at
+		FROM webhooks
+		WHERE id = $1
+	`
+
+	var hook models.Webhook
+	err := db.QueryRowContext(ctx, query, id).Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Secret, &hook.Active, &hook.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("webhook not found")
+		}
+		logger.Log.Error("Failed to retrieve webhook",
+			zap.String("webhook_id", id),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return &hook, nil
+}
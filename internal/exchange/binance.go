@@ -0,0 +1,202 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Binance combined-stream WebSocket URL
+const binanceWS = "wss://stream.binance.com:9443/ws"
+
+// Binance subscription request
+type binanceSubscription struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int64    `json:"id"`
+}
+
+// Trade message structure from the Binance <symbol>@trade stream. JSON keys
+// match fields case-insensitively, so E, t and M are declared to keep them
+// out of e, T and m.
+type binanceTrade struct {
+	EventType  string `json:"e"`
+	EventTime  int64  `json:"E"`
+	Symbol     string `json:"s"`
+	TradeID    int64  `json:"t"`
+	Price      string `json:"p"`
+	Quantity   string `json:"q"`
+	TradeTime  int64  `json:"T"`
+	BuyerMaker bool   `json:"m"`
+	Ignore     bool   `json:"M"`
+}
+
+// binanceQuotes maps canonical quote currencies to the ones Binance lists.
+// Binance has no USD spot markets; USDT is its dollar quote.
+var binanceQuotes = map[string]string{
+	"USD": "USDT",
+}
+
+// binance names markets without a separator (BTCUSDT), so the adapter keeps
+// a reverse map from venue symbol to the canonical symbols subscribed to it
+// for decoding. With USD mapped to USDT, BTCUSDT serves both BTC-USD and
+// BTC-USDT: its stream is subscribed with the first of them and only
+// unsubscribed with the last. The map is kept per connection, since a new
+// one starts without subscriptions.
+type binance struct {
+	mu        sync.RWMutex
+	conn      *websocket.Conn
+	canonical map[string][]string
+	requestID int64
+}
+
+func newBinance() *binance {
+	return &binance{canonical: make(map[string][]string)}
+}
+
+// binanceSymbol returns the Binance market of a canonical symbol
+func binanceSymbol(symbol string) string {
+	base, quote := splitSymbol(symbol)
+	if venue, ok := binanceQuotes[quote]; ok {
+		quote = venue
+	}
+	return base + quote
+}
+
+// binanceStream returns the trade stream of a Binance market
+func binanceStream(venue string) string {
+	return strings.ToLower(venue) + "@trade"
+}
+
+func (b *binance) Name() string {
+	return "binance"
+}
+
+func (b *binance) Connect(ctx context.Context) (*websocket.Conn, error) {
+	return dial(ctx, binanceWS)
+}
+
+func (b *binance) Subscribe(conn *websocket.Conn, symbols []string) error {
+	return b.send(conn, "SUBSCRIBE", b.subscribe(conn, symbols))
+}
+
+func (b *binance) Unsubscribe(conn *websocket.Conn, symbols []string) error {
+	return b.send(conn, "UNSUBSCRIBE", b.unsubscribe(conn, symbols))
+}
+
+// subscribe records symbols and returns the streams no symbol used before
+func (b *binance) subscribe(conn *websocket.Conn, symbols []string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if conn != b.conn {
+		b.conn = conn
+		b.canonical = make(map[string][]string)
+	}
+
+	var streams []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		venue := binanceSymbol(symbol)
+		if slices.Contains(b.canonical[venue], symbol) {
+			continue
+		}
+		if len(b.canonical[venue]) == 0 {
+			streams = append(streams, binanceStream(venue))
+		}
+		b.canonical[venue] = append(b.canonical[venue], symbol)
+	}
+	return streams
+}
+
+// unsubscribe forgets symbols and returns the streams no symbol uses any more
+func (b *binance) unsubscribe(conn *websocket.Conn, symbols []string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if conn != b.conn {
+		return nil
+	}
+
+	var streams []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		venue := binanceSymbol(symbol)
+		i := slices.Index(b.canonical[venue], symbol)
+		if i < 0 {
+			continue
+		}
+		b.canonical[venue] = slices.Delete(b.canonical[venue], i, i+1)
+		if len(b.canonical[venue]) == 0 {
+			delete(b.canonical, venue)
+			streams = append(streams, binanceStream(venue))
+		}
+	}
+	return streams
+}
+
+// send writes a subscription request for streams, if any
+func (b *binance) send(conn *websocket.Conn, method string, streams []string) error {
+	if len(streams) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	b.requestID++
+	id := b.requestID
+	b.mu.Unlock()
+
+	return conn.WriteJSON(binanceSubscription{
+		Method: method,
+		Params: streams,
+		ID:     id,
+	})
+}
+
+func (b *binance) Decode(message []byte) ([]models.PriceUpdate, error) {
+	var trade binanceTrade
+	if err := json.Unmarshal(message, &trade); err != nil {
+		return nil, err
+	}
+
+	// Subscription acks carry no event type
+	if trade.EventType != "trade" {
+		return nil, nil
+	}
+
+	price, err := strconv.ParseFloat(trade.Price, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := strconv.ParseFloat(trade.Quantity, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trades still in flight after an unsubscribe have no symbol left
+	b.mu.RLock()
+	symbols := slices.Clone(b.canonical[trade.Symbol])
+	b.mu.RUnlock()
+
+	updates := make([]models.PriceUpdate, 0, len(symbols))
+	for _, symbol := range symbols {
+		updates = append(updates, models.PriceUpdate{
+			Exchange:  b.Name(),
+			Symbol:    symbol,
+			Price:     price,
+			Size:      size,
+			Side:      takerSide(trade.BuyerMaker),
+			Timestamp: time.UnixMilli(trade.TradeTime).UTC().Format(time.RFC3339Nano),
+		})
+	}
+	return updates, nil
+}
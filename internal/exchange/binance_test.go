@@ -0,0 +1,103 @@
+package exchange
+
+import (
+	"reflect"
+	"testing"
+
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+const binanceTradePayload = `{"e":"trade","E":1672515782136,"s":"BTCUSDT","t":12345,"p":"16500.10000000","q":"0.00100000","b":88,"a":50,"T":1672515782134,"m":false,"M":true}`
+
+func TestBinanceSymbol(t *testing.T) {
+	for symbol, want := range map[string]string{
+		"BTC-USD":  "BTCUSDT",
+		"BTC-USDT": "BTCUSDT",
+		"eth-btc":  "ETHBTC",
+	} {
+		if got := binanceSymbol(symbol); got != want {
+			t.Errorf("binanceSymbol(%q) = %q, want %q", symbol, got, want)
+		}
+	}
+}
+
+func TestBinanceSubscriptions(t *testing.T) {
+	b := newBinance()
+	conn := &websocket.Conn{}
+
+	steps := []struct {
+		unsubscribe bool
+		symbols     []string
+		want        []string // streams (un)subscribed
+	}{
+		{false, []string{"BTC-USD"}, []string{"btcusdt@trade"}},
+		// The shared stream is already subscribed
+		{false, []string{"BTC-USDT", "ETH-USD"}, []string{"ethusdt@trade"}},
+		{false, []string{"btc-usd"}, nil},
+		// BTC-USDT still uses the stream
+		{true, []string{"BTC-USD"}, nil},
+		{true, []string{"BTC-USD"}, nil},
+		{true, []string{"BTC-USDT", "ETH-USD"}, []string{"btcusdt@trade", "ethusdt@trade"}},
+		{false, []string{"BTC-USDT"}, []string{"btcusdt@trade"}},
+	}
+
+	for i, step := range steps {
+		var got []string
+		if step.unsubscribe {
+			got = b.unsubscribe(conn, step.symbols)
+		} else {
+			got = b.subscribe(conn, step.symbols)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d: streams %v, want %v", i+1, got, step.want)
+		}
+	}
+
+	// A new connection starts without subscriptions
+	reconnected := &websocket.Conn{}
+	if got := b.unsubscribe(reconnected, []string{"BTC-USDT"}); got != nil {
+		t.Errorf("unsubscribe on a new connection = %v, want nothing", got)
+	}
+	if got := b.subscribe(reconnected, []string{"BTC-USDT"}); !reflect.DeepEqual(got, []string{"btcusdt@trade"}) {
+		t.Errorf("subscribe on a new connection = %v, want [btcusdt@trade]", got)
+	}
+}
+
+func TestBinanceDecode(t *testing.T) {
+	b := newBinance()
+	conn := &websocket.Conn{}
+
+	// Not subscribed: nothing to report it under
+	if updates, err := b.Decode([]byte(binanceTradePayload)); err != nil || len(updates) != 0 {
+		t.Errorf("Decode before subscribing = %v, %v; want no updates", updates, err)
+	}
+
+	b.subscribe(conn, []string{"BTC-USD", "BTC-USDT"})
+	updates, err := b.Decode([]byte(binanceTradePayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.PriceUpdate{
+		{Exchange: "binance", Symbol: "BTC-USD", Price: 16500.1, Size: 0.001, Side: models.TradeSideBuy, Timestamp: "2022-12-31T19:43:02.134Z"},
+		{Exchange: "binance", Symbol: "BTC-USDT", Price: 16500.1, Size: 0.001, Side: models.TradeSideBuy, Timestamp: "2022-12-31T19:43:02.134Z"},
+	}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("Decode = %+v, want %+v", updates, want)
+	}
+
+	b.unsubscribe(conn, []string{"BTC-USD"})
+	updates, _ = b.Decode([]byte(binanceTradePayload))
+	if len(updates) != 1 || updates[0].Symbol != "BTC-USDT" {
+		t.Errorf("Decode after unsubscribing BTC-USD = %+v, want BTC-USDT only", updates)
+	}
+
+	// Subscription acks
+	if updates, err := b.Decode([]byte(`{"result":null,"id":1}`)); err != nil || len(updates) != 0 {
+		t.Errorf("Decode(ack) = %v, %v; want no updates", updates, err)
+	}
+	if _, err := b.Decode([]byte(`{"e":"trade","s":"BTCUSDT","p":"x","q":"1"}`)); err == nil {
+		t.Error("Decode of a malformed price succeeded")
+	}
+}
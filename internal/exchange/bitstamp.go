@@ -0,0 +1,128 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Bitstamp WebSocket URL
+const bitstampWS = "wss://ws.bitstamp.net"
+
+// Bitstamp subscribes one channel per message
+type bitstampSubscription struct {
+	Event string              `json:"event"`
+	Data  bitstampChannelData `json:"data"`
+}
+
+type bitstampChannelData struct {
+	Channel string `json:"channel"`
+}
+
+// Message envelope from the Bitstamp live_trades channels
+type bitstampMessage struct {
+	Event   string        `json:"event"`
+	Channel string        `json:"channel"`
+	Data    bitstampTrade `json:"data"`
+}
+
+type bitstampTrade struct {
+	Price          float64 `json:"price"`
+	Amount         float64 `json:"amount"`
+	Type           int     `json:"type"` // 0 when the taker bought, 1 when it sold
+	Microtimestamp string  `json:"microtimestamp"`
+}
+
+const bitstampTradesPrefix = "live_trades_"
+
+// bitstamp names channels live_trades_btcusd, so the adapter keeps a reverse
+// map from channel to canonical symbol for decoding
+type bitstamp struct {
+	mu        sync.RWMutex
+	canonical map[string]string
+}
+
+func newBitstamp() *bitstamp {
+	return &bitstamp{canonical: make(map[string]string)}
+}
+
+func (b *bitstamp) Name() string {
+	return "bitstamp"
+}
+
+func (b *bitstamp) Connect(ctx context.Context) (*websocket.Conn, error) {
+	return dial(ctx, bitstampWS)
+}
+
+func (b *bitstamp) Subscribe(conn *websocket.Conn, symbols []string) error {
+	return b.send(conn, "bts:subscribe", symbols)
+}
+
+func (b *bitstamp) Unsubscribe(conn *websocket.Conn, symbols []string) error {
+	return b.send(conn, "bts:unsubscribe", symbols)
+}
+
+// send writes one channel request per symbol
+func (b *bitstamp) send(conn *websocket.Conn, event string, symbols []string) error {
+	for _, symbol := range symbols {
+		if err := conn.WriteJSON(bitstampSubscription{
+			Event: event,
+			Data:  bitstampChannelData{Channel: b.channel(symbol)},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *bitstamp) Decode(message []byte) ([]models.PriceUpdate, error) {
+	var msg bitstampMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, err
+	}
+
+	if msg.Event != "trade" {
+		return nil, nil
+	}
+
+	b.mu.RLock()
+	symbol, ok := b.canonical[msg.Channel]
+	b.mu.RUnlock()
+	if !ok {
+		symbol = strings.ToUpper(strings.TrimPrefix(msg.Channel, bitstampTradesPrefix))
+	}
+
+	timestamp := time.Now().UTC()
+	if micros, err := strconv.ParseInt(msg.Data.Microtimestamp, 10, 64); err == nil {
+		timestamp = time.UnixMicro(micros).UTC()
+	}
+
+	return []models.PriceUpdate{{
+		Exchange:  b.Name(),
+		Symbol:    symbol,
+		Price:     msg.Data.Price,
+		Size:      msg.Data.Amount,
+		Side:      takerSide(msg.Data.Type == 1),
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+	}}, nil
+}
+
+// channel returns the live trades channel for a canonical symbol and records
+// the reverse mapping
+func (b *bitstamp) channel(symbol string) string {
+	base, quote := splitSymbol(symbol)
+	channel := bitstampTradesPrefix + strings.ToLower(base+quote)
+
+	b.mu.Lock()
+	b.canonical[channel] = base + "-" + quote
+	b.mu.Unlock()
+
+	return channel
+}
@@ -0,0 +1,46 @@
+package exchange
+
+import (
+	"reflect"
+	"testing"
+
+	"pricenotification/internal/models"
+)
+
+const bitstampTradePayload = `{"data":{"id":264350721,"timestamp":"1672515782","amount":0.0125,"amount_str":"0.01250000","price":16500,"price_str":"16500","type":1,"microtimestamp":"1672515782134567","buy_order_id":1567339416453121,"sell_order_id":1567339418779648},"channel":"live_trades_btcusd","event":"trade"}`
+
+func TestBitstampDecode(t *testing.T) {
+	b := newBitstamp()
+	trade := models.PriceUpdate{Exchange: "bitstamp", Price: 16500, Size: 0.0125, Side: models.TradeSideSell, Timestamp: "2022-12-31T19:43:02.134567Z"}
+
+	// Before subscribing the channel name is all there is
+	trade.Symbol = "BTCUSD"
+	got, err := b.Decode([]byte(bitstampTradePayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []models.PriceUpdate{trade}) {
+		t.Errorf("Decode before subscribing = %+v, want %+v", got, trade)
+	}
+
+	if channel := b.channel("btc-usd"); channel != "live_trades_btcusd" {
+		t.Errorf("channel(btc-usd) = %q", channel)
+	}
+	trade.Symbol = "BTC-USD"
+	got, err = b.Decode([]byte(bitstampTradePayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []models.PriceUpdate{trade}) {
+		t.Errorf("Decode = %+v, want %+v", got, trade)
+	}
+
+	for _, payload := range []string{
+		`{"event":"bts:subscription_succeeded","channel":"live_trades_btcusd","data":{}}`,
+		`{"event":"bts:heartbeat","channel":"","data":{"status":"success"}}`,
+	} {
+		if updates, err := b.Decode([]byte(payload)); err != nil || len(updates) != 0 {
+			t.Errorf("Decode(%s) = %v, %v; want no updates", payload, updates, err)
+		}
+	}
+}
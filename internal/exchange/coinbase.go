@@ -0,0 +1,93 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Coinbase WebSocket URL
+const coinbaseWS = "wss://ws-feed.exchange.coinbase.com"
+
+// Coinbase WebSocket subscription format
+type coinbaseSubscription struct {
+	Type       string   `json:"type"`
+	ProductIDs []string `json:"product_ids"`
+	Channels   []string `json:"channels"`
+}
+
+// Trade message structure from the Coinbase matches channel
+type coinbaseTrade struct {
+	Type      string `json:"type"`
+	ProductID string `json:"product_id"`
+	Price     string `json:"price"`
+	Size      string `json:"size"`
+	Side      string `json:"side"` // side of the maker order
+	Time      string `json:"time"`
+}
+
+// coinbase uses canonical symbols natively, so no translation is needed
+type coinbase struct{}
+
+func newCoinbase() *coinbase {
+	return &coinbase{}
+}
+
+func (c *coinbase) Name() string {
+	return "coinbase"
+}
+
+func (c *coinbase) Connect(ctx context.Context) (*websocket.Conn, error) {
+	return dial(ctx, coinbaseWS)
+}
+
+func (c *coinbase) Subscribe(conn *websocket.Conn, symbols []string) error {
+	return conn.WriteJSON(coinbaseSubscription{
+		Type:       "subscribe",
+		ProductIDs: symbols,
+		Channels:   []string{"matches"},
+	})
+}
+
+func (c *coinbase) Unsubscribe(conn *websocket.Conn, symbols []string) error {
+	return conn.WriteJSON(coinbaseSubscription{
+		Type:       "unsubscribe",
+		ProductIDs: symbols,
+		Channels:   []string{"matches"},
+	})
+}
+
+func (c *coinbase) Decode(message []byte) ([]models.PriceUpdate, error) {
+	var trade coinbaseTrade
+	if err := json.Unmarshal(message, &trade); err != nil {
+		return nil, err
+	}
+
+	// Process only "match" messages (completed trades)
+	if trade.Type != "match" {
+		return nil, nil
+	}
+
+	price, err := strconv.ParseFloat(trade.Price, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := strconv.ParseFloat(trade.Size, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.PriceUpdate{{
+		Exchange:  c.Name(),
+		Symbol:    trade.ProductID,
+		Price:     price,
+		Size:      size,
+		Side:      takerSide(trade.Side == models.TradeSideBuy),
+		Timestamp: trade.Time,
+	}}, nil
+}
@@ -0,0 +1,51 @@
+package exchange
+
+import (
+	"reflect"
+	"testing"
+
+	"pricenotification/internal/models"
+)
+
+func TestCoinbaseDecode(t *testing.T) {
+	c := newCoinbase()
+	tests := []struct {
+		name    string
+		payload string
+		want    []models.PriceUpdate
+	}{
+		{
+			name:    "match against a resting sell",
+			payload: `{"type":"match","trade_id":10,"sequence":50,"maker_order_id":"ac928c66-ca53-498f-9c13-a110027a60e8","taker_order_id":"132fb6ae-456b-4654-b4e0-d681ac05cea1","time":"2014-11-07T08:19:27.028459Z","product_id":"BTC-USD","size":"5.23512","price":"400.23","side":"sell"}`,
+			want:    []models.PriceUpdate{{Exchange: "coinbase", Symbol: "BTC-USD", Price: 400.23, Size: 5.23512, Side: models.TradeSideBuy, Timestamp: "2014-11-07T08:19:27.028459Z"}},
+		},
+		{
+			name:    "match against a resting buy",
+			payload: `{"type":"match","time":"2014-11-07T08:19:28.464459Z","product_id":"ETH-USD","size":"1","price":"20.5","side":"buy"}`,
+			want:    []models.PriceUpdate{{Exchange: "coinbase", Symbol: "ETH-USD", Price: 20.5, Size: 1, Side: models.TradeSideSell, Timestamp: "2014-11-07T08:19:28.464459Z"}},
+		},
+		{
+			name:    "last match replayed on subscribe",
+			payload: `{"type":"last_match","time":"2014-11-07T08:19:27.028459Z","product_id":"BTC-USD","size":"1","price":"400","side":"sell"}`,
+		},
+		{
+			name:    "subscription ack",
+			payload: `{"type":"subscriptions","channels":[{"name":"matches","product_ids":["BTC-USD"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := c.Decode([]byte(tt.payload))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Decode = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := c.Decode([]byte(`{"type":"match","product_id":"BTC-USD","price":"x","size":"1"}`)); err == nil {
+		t.Error("Decode of a malformed price succeeded")
+	}
+}
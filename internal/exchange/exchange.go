@@ -0,0 +1,135 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Exchange is an adapter for a single venue's public trade feed. Symbols are
+// always passed and returned in the canonical BASE-QUOTE form (e.g. BTC-USD);
+// each adapter translates to and from its venue's own naming.
+type Exchange interface {
+	// Name returns the identifier used in PriceUpdate.Exchange
+	Name() string
+	// Connect dials the venue's WebSocket endpoint
+	Connect(ctx context.Context) (*websocket.Conn, error)
+	// Subscribe requests trades for the given symbols on an open connection
+	Subscribe(conn *websocket.Conn, symbols []string) error
+	// Unsubscribe stops trades for the given symbols on an open connection
+	Unsubscribe(conn *websocket.Conn, symbols []string) error
+	// Decode converts a raw feed message into zero or more price updates.
+	// Control messages (acks, heartbeats) decode to an empty slice.
+	Decode(message []byte) ([]models.PriceUpdate, error)
+}
+
+// registry maps config names to adapter constructors
+var registry = map[string]func() Exchange{
+	"coinbase": func() Exchange { return newCoinbase() },
+	"binance":  func() Exchange { return newBinance() },
+	"kraken":   func() Exchange { return newKraken() },
+	"bitstamp": func() Exchange { return newBitstamp() },
+}
+
+// New returns the adapter registered under name
+func New(name string) (Exchange, error) {
+	constructor, ok := registry[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange %q (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+	return constructor(), nil
+}
+
+// Names lists the supported exchange identifiers
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// connectWithBackoff dials until it succeeds or ctx is cancelled
+func connectWithBackoff(ctx context.Context, ex Exchange) *websocket.Conn {
+	backoff := 1 * time.Second
+
+	for {
+		logger.Log.Info("Connecting to exchange WebSocket", zap.String("exchange", ex.Name()))
+		conn, err := ex.Connect(ctx)
+		if err == nil {
+			logger.Log.Info("Connected to exchange WebSocket", zap.String("exchange", ex.Name()))
+			return conn
+		}
+
+		logger.Log.Warn("WebSocket connection failed",
+			zap.String("exchange", ex.Name()),
+			zap.Duration("retry_in", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// readLoop decodes messages until the connection drops or ctx is cancelled
+func readLoop(ctx context.Context, ex Exchange, conn *websocket.Conn, publish func(models.PriceUpdate)) {
+	// Unblock ReadMessage on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Log.Warn("WebSocket error", zap.String("exchange", ex.Name()), zap.Error(err))
+			}
+			return
+		}
+
+		updates, err := ex.Decode(message)
+		if err != nil {
+			logger.Log.Warn("Error parsing message", zap.String("exchange", ex.Name()), zap.Error(err))
+			continue
+		}
+
+		for _, update := range updates {
+			publish(update)
+		}
+	}
+}
+
+// dial opens a WebSocket connection to url
+func dial(ctx context.Context, url string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	return conn, err
+}
+
+// splitSymbol splits a canonical BASE-QUOTE symbol
+func splitSymbol(symbol string) (base, quote string) {
+	base, quote, _ = strings.Cut(strings.ToUpper(symbol), "-")
+	return base, quote
+}
+
+// takerSide names the side of the taker of a trade from whether the taker
+// sold, i.e. the resting order was a bid
+func takerSide(sold bool) string {
+	if sold {
+		return models.TradeSideSell
+	}
+	return models.TradeSideBuy
+}
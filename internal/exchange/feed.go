@@ -0,0 +1,151 @@
+package exchange
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Feed keeps one exchange connected and subscribed to a symbol set that can
+// change while it runs. gorilla/websocket allows a single concurrent writer,
+// so every subscription write goes through mu.
+type Feed struct {
+	ex Exchange
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	symbols map[string]bool
+}
+
+// NewFeed creates a feed for ex with an initial symbol set
+func NewFeed(ex Exchange, symbols []string) *Feed {
+	f := &Feed{ex: ex, symbols: make(map[string]bool)}
+	for _, symbol := range symbols {
+		f.symbols[symbol] = true
+	}
+	return f
+}
+
+// Name returns the underlying exchange name
+func (f *Feed) Name() string {
+	return f.ex.Name()
+}
+
+// Run keeps the feed connected until ctx is cancelled, passing every decoded
+// trade to publish. Connection failures are retried with exponential backoff
+// and the current symbol set is resubscribed after every reconnect.
+func (f *Feed) Run(ctx context.Context, publish func(models.PriceUpdate)) {
+	for ctx.Err() == nil {
+		conn := connectWithBackoff(ctx, f.ex)
+		if conn == nil {
+			return
+		}
+
+		f.mu.Lock()
+		symbols := f.symbolList()
+		var err error
+		if len(symbols) > 0 {
+			err = f.ex.Subscribe(conn, symbols)
+		}
+		if err == nil {
+			f.conn = conn
+		}
+		f.mu.Unlock()
+
+		if err != nil {
+			logger.Log.Error("Subscription failed", zap.String("exchange", f.ex.Name()), zap.Error(err))
+			conn.Close()
+			continue
+		}
+		logger.Log.Info("Subscribed to trades",
+			zap.String("exchange", f.ex.Name()),
+			zap.Strings("symbols", symbols),
+		)
+
+		readLoop(ctx, f.ex, conn, publish)
+
+		f.mu.Lock()
+		f.conn = nil
+		f.mu.Unlock()
+		conn.Close()
+	}
+}
+
+// SetSymbols replaces the symbol set, subscribing to added symbols and
+// unsubscribing from removed ones on the live connection. When the feed is
+// disconnected the new set is picked up on the next reconnect.
+func (f *Feed) SetSymbols(symbols []string) {
+	want := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		want[symbol] = true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var added, removed []string
+	for symbol := range want {
+		if !f.symbols[symbol] {
+			added = append(added, symbol)
+		}
+	}
+	for symbol := range f.symbols {
+		if !want[symbol] {
+			removed = append(removed, symbol)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	f.symbols = want
+
+	if f.conn == nil {
+		return
+	}
+
+	// A failed write means the connection is broken; closing it makes Run
+	// reconnect and resubscribe with the new set.
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if len(removed) > 0 {
+		if err := f.ex.Unsubscribe(f.conn, removed); err != nil {
+			logger.Log.Error("Unsubscribe failed", zap.String("exchange", f.ex.Name()), zap.Error(err))
+			f.conn.Close()
+			return
+		}
+		logger.Log.Info("Unsubscribed from trades",
+			zap.String("exchange", f.ex.Name()),
+			zap.Strings("symbols", removed),
+		)
+	}
+	if len(added) > 0 {
+		if err := f.ex.Subscribe(f.conn, added); err != nil {
+			logger.Log.Error("Subscription failed", zap.String("exchange", f.ex.Name()), zap.Error(err))
+			f.conn.Close()
+			return
+		}
+		logger.Log.Info("Subscribed to trades",
+			zap.String("exchange", f.ex.Name()),
+			zap.Strings("symbols", added),
+		)
+	}
+	f.conn.SetWriteDeadline(time.Time{})
+}
+
+// symbolList returns the current symbols sorted; callers must hold mu
+func (f *Feed) symbolList() []string {
+	symbols := make([]string, 0, len(f.symbols))
+	for symbol := range f.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
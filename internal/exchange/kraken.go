@@ -0,0 +1,103 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"pricenotification/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Kraken v2 WebSocket URL
+const krakenWS = "wss://ws.kraken.com/v2"
+
+// Kraken v2 subscription request
+type krakenSubscription struct {
+	Method string       `json:"method"`
+	Params krakenParams `json:"params"`
+}
+
+type krakenParams struct {
+	Channel  string   `json:"channel"`
+	Symbol   []string `json:"symbol"`
+	Snapshot bool     `json:"snapshot"`
+}
+
+// Message envelope from the Kraken v2 trade channel
+type krakenMessage struct {
+	Channel string        `json:"channel"`
+	Type    string        `json:"type"`
+	Data    []krakenTrade `json:"data"`
+}
+
+type krakenTrade struct {
+	Symbol    string  `json:"symbol"`
+	Price     float64 `json:"price"`
+	Qty       float64 `json:"qty"`
+	Side      string  `json:"side"` // side of the taker
+	Timestamp string  `json:"timestamp"`
+}
+
+// kraken names markets BASE/QUOTE, which maps one-to-one onto canonical symbols
+type kraken struct{}
+
+func newKraken() *kraken {
+	return &kraken{}
+}
+
+func (k *kraken) Name() string {
+	return "kraken"
+}
+
+func (k *kraken) Connect(ctx context.Context) (*websocket.Conn, error) {
+	return dial(ctx, krakenWS)
+}
+
+func (k *kraken) Subscribe(conn *websocket.Conn, symbols []string) error {
+	return k.send(conn, "subscribe", symbols)
+}
+
+func (k *kraken) Unsubscribe(conn *websocket.Conn, symbols []string) error {
+	return k.send(conn, "unsubscribe", symbols)
+}
+
+// send writes a trade channel request for symbols
+func (k *kraken) send(conn *websocket.Conn, method string, symbols []string) error {
+	venue := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		base, quote := splitSymbol(symbol)
+		venue = append(venue, base+"/"+quote)
+	}
+
+	return conn.WriteJSON(krakenSubscription{
+		Method: method,
+		Params: krakenParams{Channel: "trade", Symbol: venue},
+	})
+}
+
+func (k *kraken) Decode(message []byte) ([]models.PriceUpdate, error) {
+	var msg krakenMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, err
+	}
+
+	// Ignore heartbeats, status and subscription acks
+	if msg.Channel != "trade" || msg.Type != "update" {
+		return nil, nil
+	}
+
+	updates := make([]models.PriceUpdate, 0, len(msg.Data))
+	for _, trade := range msg.Data {
+		updates = append(updates, models.PriceUpdate{
+			Exchange:  k.Name(),
+			Symbol:    strings.ReplaceAll(trade.Symbol, "/", "-"),
+			Price:     trade.Price,
+			Size:      trade.Qty,
+			Side:      trade.Side,
+			Timestamp: trade.Timestamp,
+		})
+	}
+	return updates, nil
+}
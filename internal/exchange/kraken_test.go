@@ -0,0 +1,49 @@
+package exchange
+
+import (
+	"reflect"
+	"testing"
+
+	"pricenotification/internal/models"
+)
+
+func TestKrakenDecode(t *testing.T) {
+	k := newKraken()
+	tests := []struct {
+		name    string
+		payload string
+		want    []models.PriceUpdate
+	}{
+		{
+			name:    "trade update",
+			payload: `{"channel":"trade","type":"update","data":[{"symbol":"BTC/USD","side":"sell","price":16500.1,"qty":0.01,"ord_type":"market","trade_id":4665906,"timestamp":"2022-12-31T19:43:02.134567Z"},{"symbol":"BTC/USD","side":"buy","price":16500.2,"qty":0.5,"ord_type":"limit","trade_id":4665907,"timestamp":"2022-12-31T19:43:02.134567Z"}]}`,
+			want: []models.PriceUpdate{
+				{Exchange: "kraken", Symbol: "BTC-USD", Price: 16500.1, Size: 0.01, Side: models.TradeSideSell, Timestamp: "2022-12-31T19:43:02.134567Z"},
+				{Exchange: "kraken", Symbol: "BTC-USD", Price: 16500.2, Size: 0.5, Side: models.TradeSideBuy, Timestamp: "2022-12-31T19:43:02.134567Z"},
+			},
+		},
+		{
+			name:    "snapshot of past trades",
+			payload: `{"channel":"trade","type":"snapshot","data":[{"symbol":"BTC/USD","side":"buy","price":16000,"qty":1,"ord_type":"limit","trade_id":1,"timestamp":"2022-12-31T19:00:00.000000Z"}]}`,
+		},
+		{
+			name:    "subscription ack",
+			payload: `{"method":"subscribe","result":{"channel":"trade","symbol":"BTC/USD","snapshot":false},"success":true,"time_in":"2022-12-31T19:43:01.000000Z","time_out":"2022-12-31T19:43:01.000100Z"}`,
+		},
+		{
+			name:    "heartbeat",
+			payload: `{"channel":"heartbeat"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := k.Decode([]byte(tt.payload))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Decode = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
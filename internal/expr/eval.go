@@ -0,0 +1,313 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrUnavailable is returned by Eval when a value the condition needs, such
+// as the 1h change right after startup, is not known yet
+var ErrUnavailable = errors.New("value not available yet")
+
+// Env supplies the market data a condition is evaluated against. Methods
+// return false when the value is not known yet.
+type Env interface {
+	// Price is the price of the update being evaluated
+	Price() float64
+	// Size is the traded size of the update, 0 when unknown
+	Size() float64
+	// Change is the percent change of the price over window
+	Change(window time.Duration) (float64, bool)
+	// High and Low are the price range over window
+	High(window time.Duration) (float64, bool)
+	Low(window time.Duration) (float64, bool)
+	// SMA, EMA and RSI are indicators over the last n candle closes
+	SMA(n int) (float64, bool)
+	EMA(n int) (float64, bool)
+	RSI(n int) (float64, bool)
+	// Bands are the average and standard deviation of the last n closes
+	Bands(n int) (middle, stddev float64, ok bool)
+}
+
+// function describes a built-in function
+type function struct {
+	arity int
+	check func(n *node) error
+}
+
+// functions are the built-in functions of the language
+var functions = map[string]function{
+	"abs":       {arity: 1},
+	"min":       {arity: 2},
+	"max":       {arity: 2},
+	"sma":       {arity: 1, check: checkPeriod},
+	"ema":       {arity: 1, check: checkPeriod},
+	"rsi":       {arity: 1, check: checkPeriod},
+	"bb_middle": {arity: 1, check: checkPeriod},
+	"bb_upper":  {arity: 2, check: checkPeriod},
+	"bb_lower":  {arity: 2, check: checkPeriod},
+}
+
+func functionNames() string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// MaxPeriod is the longest period of an indicator function, in candles
+const MaxPeriod = 1440
+
+// checkPeriod requires a constant whole period of at most MaxPeriod
+func checkPeriod(n *node) error {
+	arg := n.args[0]
+	if arg.kind != nodeNumber || arg.num != math.Trunc(arg.num) || arg.num < 1 || arg.num > MaxPeriod {
+		return errorAt(arg.pos, "period of %s must be a whole number from 1 to %d", n.op, MaxPeriod)
+	}
+	return nil
+}
+
+// Program is a compiled condition. A program carries the state of its
+// crosses_above/crosses_below operators, so each alert needs its own and it
+// must not be evaluated concurrently.
+type Program struct {
+	source string
+	eval   func(Env) (bool, error)
+}
+
+// Compile parses and checks a condition
+func Compile(src string) (*Program, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{source: src, eval: compileBool(root)}, nil
+}
+
+// String returns the source of the program
+func (p *Program) String() string {
+	return p.source
+}
+
+// Eval reports whether the condition holds in env
+func (p *Program) Eval(env Env) (bool, error) {
+	return p.eval(env)
+}
+
+// compileNumber turns a numeric node into a closure
+func compileNumber(n *node) func(Env) (float64, error) {
+	switch n.kind {
+	case nodeNumber:
+		return func(Env) (float64, error) { return n.num, nil }
+	case nodeVar:
+		return compileVar(n)
+	case nodeCall:
+		return compileCall(n)
+	case nodeUnary:
+		operand := compileNumber(n.args[0])
+		return func(env Env) (float64, error) {
+			v, err := operand(env)
+			return -v, err
+		}
+	}
+
+	left, right := compileNumber(n.args[0]), compileNumber(n.args[1])
+	apply := map[string]func(a, b float64) float64{
+		"+": func(a, b float64) float64 { return a + b },
+		"-": func(a, b float64) float64 { return a - b },
+		"*": func(a, b float64) float64 { return a * b },
+		"/": func(a, b float64) float64 { return a / b },
+	}[n.op]
+	return func(env Env) (float64, error) {
+		a, err := left(env)
+		if err != nil {
+			return 0, err
+		}
+		b, err := right(env)
+		if err != nil {
+			return 0, err
+		}
+		if n.op == "/" && b == 0 {
+			return 0, ErrUnavailable
+		}
+		return apply(a, b), nil
+	}
+}
+
+func compileVar(n *node) func(Env) (float64, error) {
+	lookup := map[string]func(Env, time.Duration) (float64, bool){
+		"price":      func(env Env, _ time.Duration) (float64, bool) { return env.Price(), true },
+		"size":       func(env Env, _ time.Duration) (float64, bool) { return env.Size(), true },
+		"change_pct": Env.Change,
+		"high":       Env.High,
+		"low":        Env.Low,
+	}[n.op]
+	return func(env Env) (float64, error) {
+		v, ok := lookup(env, n.window)
+		if !ok {
+			return 0, ErrUnavailable
+		}
+		return v, nil
+	}
+}
+
+func compileCall(n *node) func(Env) (float64, error) {
+	switch n.op {
+	case "sma", "ema", "rsi", "bb_middle":
+		return compileIndicator(n)
+	case "bb_upper", "bb_lower":
+		return compileBand(n)
+	}
+
+	args := make([]func(Env) (float64, error), len(n.args))
+	for i, arg := range n.args {
+		args[i] = compileNumber(arg)
+	}
+	apply := map[string]func([]float64) float64{
+		"abs": func(v []float64) float64 { return math.Abs(v[0]) },
+		"min": func(v []float64) float64 { return math.Min(v[0], v[1]) },
+		"max": func(v []float64) float64 { return math.Max(v[0], v[1]) },
+	}[n.op]
+	return func(env Env) (float64, error) {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			v, err := arg(env)
+			if err != nil {
+				return 0, err
+			}
+			values[i] = v
+		}
+		return apply(values), nil
+	}
+}
+
+// compileIndicator looks up an indicator of a constant period
+func compileIndicator(n *node) func(Env) (float64, error) {
+	period := int(n.args[0].num)
+	lookup := map[string]func(Env, int) (float64, bool){
+		"sma": Env.SMA,
+		"ema": Env.EMA,
+		"rsi": Env.RSI,
+		"bb_middle": func(env Env, n int) (float64, bool) {
+			middle, _, ok := env.Bands(n)
+			return middle, ok
+		},
+	}[n.op]
+	return func(env Env) (float64, error) {
+		v, ok := lookup(env, period)
+		if !ok {
+			return 0, ErrUnavailable
+		}
+		return v, nil
+	}
+}
+
+// compileBand builds a Bollinger band: k standard deviations above or
+// below the average of the last n closes
+func compileBand(n *node) func(Env) (float64, error) {
+	period := int(n.args[0].num)
+	width := compileNumber(n.args[1])
+	sign := 1.0
+	if n.op == "bb_lower" {
+		sign = -1
+	}
+	return func(env Env) (float64, error) {
+		k, err := width(env)
+		if err != nil {
+			return 0, err
+		}
+		middle, stddev, ok := env.Bands(period)
+		if !ok {
+			return 0, ErrUnavailable
+		}
+		return middle + sign*k*stddev, nil
+	}
+}
+
+// compileBool turns a boolean node into a closure. && and || evaluate both
+// operands so crossing operators on either side always see every update.
+func compileBool(n *node) func(Env) (bool, error) {
+	if n.kind == nodeUnary {
+		operand := compileBool(n.args[0])
+		return func(env Env) (bool, error) {
+			v, err := operand(env)
+			return !v, err
+		}
+	}
+
+	switch n.op {
+	case "&&", "||":
+		left, right := compileBool(n.args[0]), compileBool(n.args[1])
+		return func(env Env) (bool, error) {
+			a, errA := left(env)
+			b, errB := right(env)
+			if err := errors.Join(errA, errB); err != nil {
+				return false, err
+			}
+			if n.op == "&&" {
+				return a && b, nil
+			}
+			return a || b, nil
+		}
+	case "crosses_above", "crosses_below":
+		return compileCrossing(n)
+	}
+
+	left, right := compileNumber(n.args[0]), compileNumber(n.args[1])
+	compare := map[string]func(a, b float64) bool{
+		"==": func(a, b float64) bool { return a == b },
+		"!=": func(a, b float64) bool { return a != b },
+		"<":  func(a, b float64) bool { return a < b },
+		"<=": func(a, b float64) bool { return a <= b },
+		">":  func(a, b float64) bool { return a > b },
+		">=": func(a, b float64) bool { return a >= b },
+	}[n.op]
+	return func(env Env) (bool, error) {
+		a, err := left(env)
+		if err != nil {
+			return false, err
+		}
+		b, err := right(env)
+		if err != nil {
+			return false, err
+		}
+		return compare(a, b), nil
+	}
+}
+
+// compileCrossing builds a crossing operator, which holds on the update
+// where the left operand moves from at or below (above) the right one to
+// strictly above (below) it
+func compileCrossing(n *node) func(Env) (bool, error) {
+	left, right := compileNumber(n.args[0]), compileNumber(n.args[1])
+	var prevDiff float64
+	var hasPrev bool
+
+	return func(env Env) (bool, error) {
+		a, err := left(env)
+		if err != nil {
+			return false, err
+		}
+		b, err := right(env)
+		if err != nil {
+			return false, err
+		}
+
+		diff := a - b
+		crossed := false
+		if hasPrev {
+			if n.op == "crosses_above" {
+				crossed = prevDiff <= 0 && diff > 0
+			} else {
+				crossed = prevDiff >= 0 && diff < 0
+			}
+		}
+		prevDiff, hasPrev = diff, true
+		return crossed, nil
+	}
+}
@@ -0,0 +1,136 @@
+// Package expr implements the alert condition language: arithmetic and
+// comparisons over price variables, e.g.
+//
+//	price > 70000 && change_1h_pct > 3
+//	sma(20) crosses_above sma(50)
+//	rsi(14) > 70
+//
+// Conditions are parsed and checked once by Compile and evaluated many
+// times against an Env.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// tokenKind classifies a token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+// token is one lexeme with its byte offset in the source
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of condition"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Error is a parse or check error at a position of the source
+type Error struct {
+	Pos int    // 1-based column
+	Msg string // what went wrong
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+func errorAt(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// operators lists the symbolic operators, longest first
+var operators = []string{"&&", "||", ">=", "<=", "==", "!=", ">", "<", "+", "-", "*", "/", "!"}
+
+// wordOperators are identifiers that act as operators
+var wordOperators = map[string]string{
+	"and":           "&&",
+	"or":            "||",
+	"not":           "!",
+	"crosses_above": "crosses_above",
+	"crosses_below": "crosses_below",
+}
+
+// lex splits src into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, errorAt(start, "invalid number %q", src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, pos: start})
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			word := src[start:i]
+			if op, ok := wordOperators[word]; ok {
+				tokens = append(tokens, token{kind: tokOp, text: op, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		default:
+			matched := false
+			for _, op := range operators {
+				if len(src)-i >= len(op) && src[i:i+len(op)] == op {
+					tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errorAt(i, "unexpected character %q", c)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
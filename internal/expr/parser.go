@@ -0,0 +1,286 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxWindow is the longest look-back a condition may use
+const MaxWindow = 24 * time.Hour
+
+// valueType is the static type of an expression
+type valueType int
+
+const (
+	number valueType = iota
+	boolean
+)
+
+func (t valueType) String() string {
+	if t == boolean {
+		return "boolean"
+	}
+	return "number"
+}
+
+// nodeKind classifies syntax tree nodes
+type nodeKind int
+
+const (
+	nodeNumber nodeKind = iota
+	nodeVar
+	nodeCall
+	nodeUnary
+	nodeBinary
+)
+
+// node is a checked syntax tree node
+type node struct {
+	kind   nodeKind
+	typ    valueType
+	pos    int
+	op     string // operator, variable or function name
+	num    float64
+	window time.Duration // look-back of windowed variables
+	args   []*node
+}
+
+// binding powers of the infix operators
+var precedence = map[string]int{
+	"||":            1,
+	"&&":            2,
+	"==":            3,
+	"!=":            3,
+	"<":             3,
+	"<=":            3,
+	">":             3,
+	">=":            3,
+	"crosses_above": 3,
+	"crosses_below": 3,
+	"+":             4,
+	"-":             4,
+	"*":             5,
+	"/":             5,
+}
+
+// Binding powers of the prefix operators: unary minus binds tighter than
+// any infix operator, while not binds looser than comparisons, so that
+// not price > 1 negates the comparison, and tighter than && and ||
+const (
+	prefixPower = 6
+	notPower    = 2
+)
+
+// parser is a Pratt parser over the token list
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parse parses a full condition, which must be boolean
+func parse(src string) (*node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(t.pos, "unexpected %s", t)
+	}
+	if n.typ != boolean {
+		return nil, errorAt(n.pos, "condition must be a comparison, got a number")
+	}
+	return n, nil
+}
+
+// expression parses operators binding tighter than minPower
+func (p *parser) expression(minPower int) (*node, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		power, ok := precedence[t.text]
+		if t.kind != tokOp || !ok || power <= minPower {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.expression(power)
+		if err != nil {
+			return nil, err
+		}
+
+		left, err = binary(t, left, right)
+		if err != nil {
+			return nil, err
+		}
+
+		// Comparisons do not chain: a < b < c is rejected
+		if power == 3 {
+			if next := p.peek(); next.kind == tokOp && precedence[next.text] == 3 {
+				return nil, errorAt(next.pos, "comparisons cannot be chained; combine them with &&")
+			}
+		}
+	}
+}
+
+// prefix parses a primary expression or a unary operator
+func (p *parser) prefix() (*node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		return &node{kind: nodeNumber, typ: number, pos: t.pos, num: t.num}, nil
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			return p.call(t)
+		}
+		return variable(t)
+	case tokLParen:
+		n, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, errorAt(closing.pos, "expected ')' to close '(' at column %d, got %s", t.pos+1, closing)
+		}
+		return n, nil
+	case tokOp:
+		if t.text != "-" && t.text != "!" {
+			break
+		}
+		want, power := number, prefixPower
+		if t.text == "!" {
+			want, power = boolean, notPower
+		}
+		operand, err := p.expression(power)
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ != want {
+			return nil, errorAt(operand.pos, "%q needs a %s operand, got a %s", t.text, want, operand.typ)
+		}
+		return &node{kind: nodeUnary, typ: want, pos: t.pos, op: t.text, args: []*node{operand}}, nil
+	}
+
+	return nil, errorAt(t.pos, "expected a number, variable or '(', got %s", t)
+}
+
+// call parses the arguments of a function call
+func (p *parser) call(name token) (*node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, errorAt(name.pos, "unknown function %q (known: %s)", name.text, functionNames())
+	}
+	p.next() // (
+
+	var args []*node
+	if p.peek().kind != tokRParen {
+		for {
+			arg, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			if arg.typ != number {
+				return nil, errorAt(arg.pos, "arguments of %s must be numbers", name.text)
+			}
+			args = append(args, arg)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if closing := p.next(); closing.kind != tokRParen {
+		return nil, errorAt(closing.pos, "expected ',' or ')' in call of %s, got %s", name.text, closing)
+	}
+
+	if len(args) != fn.arity {
+		return nil, errorAt(name.pos, "%s takes %d argument(s), got %d", name.text, fn.arity, len(args))
+	}
+	n := &node{kind: nodeCall, typ: number, pos: name.pos, op: name.text, args: args}
+	if fn.check != nil {
+		if err := fn.check(n); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// binary builds and type checks an infix operation
+func binary(op token, left, right *node) (*node, error) {
+	n := &node{kind: nodeBinary, pos: left.pos, op: op.text, args: []*node{left, right}}
+
+	operands := number
+	switch op.text {
+	case "&&", "||":
+		operands, n.typ = boolean, boolean
+	case "+", "-", "*", "/":
+		n.typ = number
+	default:
+		n.typ = boolean
+	}
+
+	for _, arg := range n.args {
+		if arg.typ != operands {
+			return nil, errorAt(arg.pos, "%q needs %s operands, got a %s", op.text, operands, arg.typ)
+		}
+	}
+	return n, nil
+}
+
+// variable resolves a variable name. Windowed variables carry their
+// look-back in the name, e.g. change_15m_pct or high_24h.
+func variable(t token) (*node, error) {
+	n := &node{kind: nodeVar, typ: number, pos: t.pos, op: t.text}
+
+	switch name := t.text; {
+	case name == "price" || name == "size":
+		return n, nil
+	case strings.HasPrefix(name, "change_") && strings.HasSuffix(name, "_pct"):
+		window, err := parseWindow(t, strings.TrimSuffix(strings.TrimPrefix(name, "change_"), "_pct"))
+		n.op, n.window = "change_pct", window
+		return n, err
+	case strings.HasPrefix(name, "high_"), strings.HasPrefix(name, "low_"):
+		prefix, suffix, _ := strings.Cut(name, "_")
+		window, err := parseWindow(t, suffix)
+		n.op, n.window = prefix, window
+		return n, err
+	}
+
+	return nil, errorAt(t.pos, "unknown variable %q (known: price, size, change_<window>_pct, high_<window>, low_<window>)", t.text)
+}
+
+// parseWindow parses look-backs such as 15m or 4h
+func parseWindow(t token, s string) (time.Duration, error) {
+	if len(s) >= 2 {
+		count, err := strconv.Atoi(s[:len(s)-1])
+		unit := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour}[s[len(s)-1]]
+		// Bound the count before multiplying, which could overflow
+		if err == nil && unit > 0 && count > 0 && count <= int(MaxWindow/unit) {
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return 0, errorAt(t.pos, "invalid window %q in %q: expected minutes or hours up to 24h, e.g. 15m or 4h", s, t.text)
+}
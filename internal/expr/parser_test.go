@@ -0,0 +1,176 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testEnv is a fixed market: every windowed value and indicator is known
+type testEnv struct {
+	price, size float64
+	change      map[time.Duration]float64
+}
+
+func (e testEnv) Price() float64 { return e.price }
+func (e testEnv) Size() float64  { return e.size }
+
+func (e testEnv) Change(window time.Duration) (float64, bool) {
+	v, ok := e.change[window]
+	return v, ok
+}
+
+func (e testEnv) High(time.Duration) (float64, bool) { return e.price + 10, true }
+func (e testEnv) Low(time.Duration) (float64, bool)  { return e.price - 10, true }
+func (e testEnv) SMA(n int) (float64, bool)          { return float64(n), true }
+func (e testEnv) EMA(n int) (float64, bool)          { return float64(n), true }
+func (e testEnv) RSI(int) (float64, bool)            { return 50, true }
+
+func (e testEnv) Bands(n int) (middle, stddev float64, ok bool) {
+	return float64(n), 1, true
+}
+
+func TestEval(t *testing.T) {
+	env := testEnv{price: 100, size: 2, change: map[time.Duration]float64{time.Hour: 3}}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// Precedence: * over +, arithmetic over comparisons, && over ||
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"10 - 4 - 3 == 3", true},
+		{"12 / 3 / 2 == 2", true},
+		{"price - 50 > 40", true},
+		{"-price + 200 == 100", true},
+		{"price > 1 || price < 0 && size > 5", true},
+		{"(price > 1 || price < 0) && size > 5", false},
+		{"price > 1 and size == 2", true},
+		{"price < 1 or size == 2", true},
+
+		// not negates the whole comparison and binds tighter than && and ||
+		{"not price > 1", false},
+		{"!price < 1", true},
+		{"not price > 1 || size == 2", true},
+		{"not (price > 1 || size == 2)", false},
+		{"not price > 1 && size == 2", false},
+		{"!!(price > 1)", true},
+		{"not -price > 0", true},
+
+		// Variables and functions
+		{"change_1h_pct == 3", true},
+		{"change_60m_pct == 3", true},
+		{"high_4h - low_4h == 20", true},
+		{"abs(-3) == 3 && min(1, 2) == 1 && max(1, 2) == 2", true},
+		{"sma(20) < ema(50)", true},
+		{"bb_upper(20, 2) == 22 && bb_lower(20, 2) == 18", true},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(tt.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := program.Eval(env)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalUnavailable(t *testing.T) {
+	env := testEnv{price: 100}
+	for _, src := range []string{"change_15m_pct > 1", "price / 0 > 1"} {
+		program, err := Compile(src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", src, err)
+		}
+		if _, err := program.Eval(env); !errors.Is(err, ErrUnavailable) {
+			t.Errorf("Eval(%q) error = %v, want ErrUnavailable", src, err)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		want time.Duration
+		ok   bool
+	}{
+		{"change_1m_pct", time.Minute, true},
+		{"change_15m_pct", 15 * time.Minute, true},
+		{"high_24h", 24 * time.Hour, true},
+		{"low_1440m", 24 * time.Hour, true},
+		{"change_25h_pct", 0, false},
+		{"low_1441m", 0, false},
+		{"change_0m_pct", 0, false},
+		{"change_15s_pct", 0, false},
+		{"high_h", 0, false},
+		// Counts that overflow time.Duration when multiplied by the unit
+		{"change_153722867280912931m_pct", 0, false},
+		{"high_2562047788015216h", 0, false},
+	}
+
+	for _, tt := range tests {
+		n, err := variable(token{kind: tokIdent, text: tt.name})
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("variable(%q) = window %v, want an error", tt.name, n.window)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("variable(%q): %v", tt.name, err)
+			continue
+		}
+		if n.window != tt.want {
+			t.Errorf("variable(%q) window = %v, want %v", tt.name, n.window, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int // 1-based column reported in the error
+	}{
+		// Unknown identifiers
+		{"prcie > 70000", 1},
+		{"price > 1 && volume > 2", 14},
+		{"price > smaa(20)", 9},
+		{"change_153722867280912931m_pct > 1", 1},
+
+		// Syntax and types
+		{"price >", 8},
+		{"price > 1 )", 11},
+		{"(price > 1", 11},
+		{"price + 1", 1},
+		{"1 < price < 2", 11},
+		{"not price", 5},
+		{"-(price > 1) > 0", 3},
+		{"price > 1 && 2", 14},
+		{"sma(0) > 1", 5},
+		{"sma(price) > 1", 5},
+		{"min(1) > 0", 1},
+		{"price # 1", 7},
+		{"", 1},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		var exprErr *Error
+		if !errors.As(err, &exprErr) {
+			t.Errorf("Compile(%q) error = %v, want an *Error", tt.src, err)
+			continue
+		}
+		if exprErr.Pos != tt.pos {
+			t.Errorf("Compile(%q) error at column %d (%s), want column %d", tt.src, exprErr.Pos, exprErr.Msg, tt.pos)
+		}
+	}
+}
@@ -1,19 +1,28 @@
 package handlers
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"pricenotification/internal/alertstate"
 	"pricenotification/internal/cache"
+	"pricenotification/internal/candles"
 	"pricenotification/internal/database"
+	"pricenotification/internal/exchange"
+	"pricenotification/internal/expr"
 	"pricenotification/internal/logger"
 	"pricenotification/internal/models"
+	"pricenotification/internal/pricewindow"
 
 	"github.com/google/uuid"
 
@@ -27,16 +36,96 @@ type Response struct {
 }
 
 type CreateAlertRequest struct {
-	UserID         string   `json:"user_id"`
-	Symbol         string   `json:"symbol"`
-	UpperThreshold *float64 `json:"upper_threshold,omitempty"`
-	LowerThreshold *float64 `json:"lower_threshold,omitempty"`
+	UserID         string     `json:"user_id"`
+	Symbol         string     `json:"symbol"`
+	Exchange       string     `json:"exchange,omitempty"`
+	Type           string     `json:"type,omitempty"`
+	UpperThreshold *float64   `json:"upper_threshold,omitempty"`
+	LowerThreshold *float64   `json:"lower_threshold,omitempty"`
+	ChangePct      *float64   `json:"change_pct,omitempty"`
+	WindowMinutes  int        `json:"window_minutes,omitempty"`
+	Direction      string     `json:"direction,omitempty"`
+	ReferencePrice *float64   `json:"reference_price,omitempty"`
+	TrailAmount    *float64   `json:"trail_amount,omitempty"`
+	Formula        string     `json:"formula,omitempty"`
+	LegSymbol      string     `json:"leg_symbol,omitempty"`
+	LegExchange    string     `json:"leg_exchange,omitempty"`
+	StaleSeconds   int        `json:"stale_seconds,omitempty"`
+	Multiplier     *float64   `json:"multiplier,omitempty"`
+	BaselineWindow int        `json:"baseline_minutes,omitempty"`
+	MinSize        *float64   `json:"min_size,omitempty"`
+	TradeSide      string     `json:"trade_side,omitempty"`
+	Condition      string     `json:"condition,omitempty"`
+	Interval       string     `json:"interval,omitempty"`
+	OnClose        bool       `json:"on_close,omitempty"`
+	Hysteresis     *float64   `json:"hysteresis,omitempty"` // in the unit of the level: price, percentage points of change_pct, or multiples of multiplier
+	SustainSeconds int        `json:"sustain_seconds,omitempty"`
+	SustainUpdates int        `json:"sustain_updates,omitempty"`
+	Mode           string     `json:"mode,omitempty"`
+	Cooldown       int        `json:"cooldown_seconds,omitempty"`
+	MaxTriggers    int        `json:"max_triggers,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	EscalationID   string     `json:"escalation_id,omitempty"`
 }
 
 type UpdateAlertRequest struct {
-	Symbol         string   `json:"symbol,omitempty"`
-	UpperThreshold *float64 `json:"upper_threshold,omitempty"`
-	LowerThreshold *float64 `json:"lower_threshold,omitempty"`
+	Symbol         string     `json:"symbol,omitempty"`
+	Exchange       string     `json:"exchange,omitempty"`
+	Type           string     `json:"type,omitempty"`
+	UpperThreshold *float64   `json:"upper_threshold,omitempty"`
+	LowerThreshold *float64   `json:"lower_threshold,omitempty"`
+	ChangePct      *float64   `json:"change_pct,omitempty"`
+	WindowMinutes  *int       `json:"window_minutes,omitempty"`
+	Direction      *string    `json:"direction,omitempty"`
+	ReferencePrice *float64   `json:"reference_price,omitempty"`
+	TrailAmount    *float64   `json:"trail_amount,omitempty"`
+	Formula        *string    `json:"formula,omitempty"`
+	LegSymbol      *string    `json:"leg_symbol,omitempty"`
+	LegExchange    *string    `json:"leg_exchange,omitempty"`
+	StaleSeconds   *int       `json:"stale_seconds,omitempty"`
+	Multiplier     *float64   `json:"multiplier,omitempty"`
+	BaselineWindow *int       `json:"baseline_minutes,omitempty"`
+	MinSize        *float64   `json:"min_size,omitempty"`
+	TradeSide      *string    `json:"trade_side,omitempty"`
+	Condition      *string    `json:"condition,omitempty"`
+	Interval       *string    `json:"interval,omitempty"`
+	OnClose        *bool      `json:"on_close,omitempty"`
+	Hysteresis     *float64   `json:"hysteresis,omitempty"` // in the unit of the level, as on create
+	SustainSeconds *int       `json:"sustain_seconds,omitempty"`
+	SustainUpdates *int       `json:"sustain_updates,omitempty"`
+	Mode           *string    `json:"mode,omitempty"`
+	Cooldown       *int       `json:"cooldown_seconds,omitempty"`
+	MaxTriggers    *int       `json:"max_triggers,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	EscalationID   *string    `json:"escalation_id,omitempty"` // empty to stop escalating
+	Status         *string    `json:"status,omitempty"`        // active or paused; active re-arms a triggered alert
+}
+
+// ConditionError is the response body of a condition that does not compile
+type ConditionError struct {
+	Position int    `json:"position"` // 1-based column
+	Error    string `json:"error"`
+}
+
+// symbolPattern matches canonical BASE-QUOTE symbols, which every exchange
+// adapter knows how to translate
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,10}-[A-Z0-9]{2,10}$`)
+
+// normalizeSymbol upper-cases a symbol and reports whether it is canonical
+func normalizeSymbol(symbol string) (string, bool) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	return symbol, symbolPattern.MatchString(symbol)
+}
+
+// normalizeExchange lower-cases an exchange name, defaulting to the
+// consolidated price, and reports whether it names a known price stream
+func normalizeExchange(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == models.ConsolidatedExchange {
+		return models.ConsolidatedExchange, true
+	}
+	_, err := exchange.New(name)
+	return name, err == nil
 }
 
 // AlertsHandler handles all alert operations based on the HTTP method
@@ -46,6 +135,16 @@ func AlertsHandler(w http.ResponseWriter, r *http.Request, instance string) {
 	path := r.URL.Path
 	pathParts := strings.Split(path, "/")
 	
+	// Bulk status actions: /alerts/{action}
+	if len(pathParts) == 3 && isStatusAction(pathParts[2]) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		BulkAlertStatusHandler(w, r, pathParts[2], instance)
+		return
+	}
+
 	// Root alerts endpoint
 	if len(pathParts) <= 2 || pathParts[2] == "" {
 		// Handle collection endpoints
@@ -63,6 +162,27 @@ func AlertsHandler(w http.ResponseWriter, r *http.Request, instance string) {
 	// Get alert ID from path
 	alertID := pathParts[2]
 	
+	// Handle alert sub-resources: /alerts/{id}/{resource}
+	if len(pathParts) > 3 && pathParts[3] != "" {
+		switch {
+		case pathParts[3] == "history" && r.Method == http.MethodGet:
+			AlertHistoryHandler(w, r, alertID, instance)
+		case pathParts[3] == "history":
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		case isStatusAction(pathParts[3]) && r.Method == http.MethodPost:
+			AlertStatusHandler(w, r, alertID, pathParts[3], instance)
+		case isStatusAction(pathParts[3]):
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		case pathParts[3] == "ack" && r.Method == http.MethodPost:
+			AcknowledgeAlertHandler(w, r, alertID, instance)
+		case pathParts[3] == "ack":
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+	
 	// Handle single alert endpoints
 	switch r.Method {
 	case http.MethodGet:
@@ -126,6 +246,16 @@ func BrowseAlertsHandler(w http.ResponseWriter, r *http.Request, instance string
 		return
 	}
 
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := make([]*models.Alert, 0, len(alerts))
+		for _, alert := range alerts {
+			if alert.Status == status {
+				filtered = append(filtered, alert)
+			}
+		}
+		alerts = filtered
+	}
+
 	response := Response{
 		Message: "Alerts retrieved successfully",
 		Data:    alerts,
@@ -181,11 +311,32 @@ func CreateAlertHandler(w http.ResponseWriter, r *http.Request, instance string)
 		return
 	}
 
-	if req.UpperThreshold == nil && req.LowerThreshold == nil {
-		logger.Log.Error("At least one threshold must be specified",
+	symbol, ok := normalizeSymbol(req.Symbol)
+	if !ok {
+		logger.Log.Error("Invalid symbol",
 			zap.String("trace_id", traceID),
+			zap.String("symbol", req.Symbol),
 		)
-		http.Error(w, "At least one threshold (upper or lower) must be specified", http.StatusBadRequest)
+		http.Error(w, "Invalid symbol: expected BASE-QUOTE, e.g. BTC-USD", http.StatusBadRequest)
+		return
+	}
+
+	exchangeName, ok := normalizeExchange(req.Exchange)
+	if !ok {
+		logger.Log.Error("Invalid exchange",
+			zap.String("trace_id", traceID),
+			zap.String("exchange", req.Exchange),
+		)
+		http.Error(w, "Invalid exchange: expected consolidated or one of "+strings.Join(exchange.Names(), ", "), http.StatusBadRequest)
+		return
+	}
+
+	var hysteresis float64
+	if req.Hysteresis != nil {
+		hysteresis = *req.Hysteresis
+	}
+	if hysteresis < 0 {
+		http.Error(w, "Invalid hysteresis: must not be negative", http.StatusBadRequest)
 		return
 	}
 
@@ -194,13 +345,67 @@ func CreateAlertHandler(w http.ResponseWriter, r *http.Request, instance string)
 	alert := &models.Alert{
 		ID:             uuid.New().String(),
 		UserID:         req.UserID,
-		Symbol:         req.Symbol,
+		Symbol:         symbol,
+		Exchange:       exchangeName,
+		Type:           req.Type,
 		UpperThreshold: req.UpperThreshold,
 		LowerThreshold: req.LowerThreshold,
+		ChangePct:      req.ChangePct,
+		WindowMinutes:  req.WindowMinutes,
+		Direction:      req.Direction,
+		ReferencePrice: req.ReferencePrice,
+		TrailAmount:    req.TrailAmount,
+		Formula:        req.Formula,
+		LegSymbol:      req.LegSymbol,
+		LegExchange:    req.LegExchange,
+		StaleSeconds:   req.StaleSeconds,
+		Multiplier:     req.Multiplier,
+		BaselineWindow: req.BaselineWindow,
+		MinSize:        req.MinSize,
+		TradeSide:      req.TradeSide,
+		Condition:      strings.TrimSpace(req.Condition),
+		Interval:       req.Interval,
+		OnClose:        req.OnClose,
+		Hysteresis:     hysteresis,
+		SustainSeconds: req.SustainSeconds,
+		SustainUpdates: req.SustainUpdates,
+		Mode:           req.Mode,
+		Cooldown:       req.Cooldown,
+		MaxTriggers:    req.MaxTriggers,
+		Status:         models.StatusActive,
+		ExpiresAt:      req.ExpiresAt,
+		EscalationID:   req.EscalationID,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
 
+	if err := validateAlertType(ctx, alert); err != nil {
+		logger.Log.Error("Invalid alert",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		writeAlertError(w, err)
+		return
+	}
+
+	if err := validateLifecycle(alert, now); err != nil {
+		logger.Log.Error("Invalid alert lifecycle",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAlertEscalation(ctx, alert); err != nil {
+		logger.Log.Error("Invalid alert escalation",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := database.CreateAlert(ctx, alert); err != nil {
 		logger.Log.Error("Failed to create alert",
 			zap.String("trace_id", traceID),
@@ -243,6 +448,14 @@ func GetAlertHandler(w http.ResponseWriter, r *http.Request, alertID string, ins
 		return
 	}
 
+	if err := trailingState(ctx, alert); err != nil {
+		logger.Log.Warn("Failed to read trailing alert state",
+			zap.String("trace_id", traceID),
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+	}
+
 	response := Response{
 		Message: "Alert retrieved successfully",
 		Data:    alert,
@@ -286,7 +499,29 @@ func UpdateAlertHandler(w http.ResponseWriter, r *http.Request, alertID string,
 
 	// Update fields if provided
 	if req.Symbol != "" {
-		existingAlert.Symbol = req.Symbol
+		symbol, ok := normalizeSymbol(req.Symbol)
+		if !ok {
+			logger.Log.Error("Invalid symbol",
+				zap.String("trace_id", traceID),
+				zap.String("symbol", req.Symbol),
+			)
+			http.Error(w, "Invalid symbol: expected BASE-QUOTE, e.g. BTC-USD", http.StatusBadRequest)
+			return
+		}
+		existingAlert.Symbol = symbol
+	}
+
+	if req.Exchange != "" {
+		exchangeName, ok := normalizeExchange(req.Exchange)
+		if !ok {
+			logger.Log.Error("Invalid exchange",
+				zap.String("trace_id", traceID),
+				zap.String("exchange", req.Exchange),
+			)
+			http.Error(w, "Invalid exchange: expected consolidated or one of "+strings.Join(exchange.Names(), ", "), http.StatusBadRequest)
+			return
+		}
+		existingAlert.Exchange = exchangeName
 	}
 	
 	if req.UpperThreshold != nil {
@@ -297,19 +532,180 @@ func UpdateAlertHandler(w http.ResponseWriter, r *http.Request, alertID string,
 		existingAlert.LowerThreshold = req.LowerThreshold
 	}
 
-	// Ensure at least one threshold is set
-	if existingAlert.UpperThreshold == nil && existingAlert.LowerThreshold == nil {
-		logger.Log.Error("At least one threshold must be specified",
+	if req.Hysteresis != nil {
+		if *req.Hysteresis < 0 {
+			http.Error(w, "Invalid hysteresis: must not be negative", http.StatusBadRequest)
+			return
+		}
+		existingAlert.Hysteresis = *req.Hysteresis
+	}
+
+	if req.SustainSeconds != nil {
+		existingAlert.SustainSeconds = *req.SustainSeconds
+	}
+
+	if req.SustainUpdates != nil {
+		existingAlert.SustainUpdates = *req.SustainUpdates
+	}
+
+	if req.Mode != nil {
+		existingAlert.Mode = *req.Mode
+	}
+
+	if req.Cooldown != nil {
+		existingAlert.Cooldown = *req.Cooldown
+	}
+
+	if req.MaxTriggers != nil {
+		existingAlert.MaxTriggers = *req.MaxTriggers
+	}
+
+	if req.ExpiresAt != nil {
+		existingAlert.ExpiresAt = req.ExpiresAt
+	}
+
+	if req.EscalationID != nil {
+		existingAlert.EscalationID = *req.EscalationID
+	}
+
+	// The status is written against the stored one, which the processing
+	// service may have changed since the alert was read
+	var status string
+	if req.Status != nil {
+		if *req.Status != models.StatusActive && *req.Status != models.StatusPaused {
+			http.Error(w, "Invalid status: expected active or paused", http.StatusBadRequest)
+			return
+		}
+		status = *req.Status
+		existingAlert.Status = status
+	}
+
+	wasTrailing := existingAlert.Type == models.AlertTypeTrailing
+	trailDirection := existingAlert.Direction
+
+	if req.Type != "" {
+		existingAlert.Type = req.Type
+	}
+
+	if req.ChangePct != nil {
+		existingAlert.ChangePct = req.ChangePct
+	}
+
+	if req.WindowMinutes != nil {
+		existingAlert.WindowMinutes = *req.WindowMinutes
+	}
+
+	if req.Direction != nil {
+		existingAlert.Direction = *req.Direction
+	}
+
+	if req.ReferencePrice != nil {
+		existingAlert.ReferencePrice = req.ReferencePrice
+	}
+
+	if req.TrailAmount != nil {
+		existingAlert.TrailAmount = req.TrailAmount
+	}
+
+	if req.Formula != nil {
+		existingAlert.Formula = *req.Formula
+	}
+
+	if req.LegSymbol != nil {
+		existingAlert.LegSymbol = *req.LegSymbol
+	}
+
+	if req.LegExchange != nil {
+		existingAlert.LegExchange = *req.LegExchange
+	}
+
+	if req.StaleSeconds != nil {
+		existingAlert.StaleSeconds = *req.StaleSeconds
+	}
+
+	if req.Multiplier != nil {
+		existingAlert.Multiplier = req.Multiplier
+	}
+
+	if req.BaselineWindow != nil {
+		existingAlert.BaselineWindow = *req.BaselineWindow
+	}
+
+	if req.MinSize != nil {
+		existingAlert.MinSize = req.MinSize
+	}
+
+	if req.TradeSide != nil {
+		existingAlert.TradeSide = *req.TradeSide
+	}
+
+	if req.Condition != nil {
+		existingAlert.Condition = strings.TrimSpace(*req.Condition)
+	}
+
+	if req.Interval != nil {
+		existingAlert.Interval = *req.Interval
+	}
+
+	if req.OnClose != nil {
+		existingAlert.OnClose = *req.OnClose
+	}
+
+	// Only indicator alerts have a candle interval
+	if existingAlert.Type != models.AlertTypeIndicator && req.Interval == nil && req.OnClose == nil {
+		existingAlert.Interval, existingAlert.OnClose = "", false
+	}
+
+	if existingAlert.Type == models.AlertTypeTrailing {
+		// A trailing alert retraces by either a percentage or an amount,
+		// so setting one replaces the other
+		if (req.ChangePct == nil) != (req.TrailAmount == nil) {
+			existingAlert.ChangePct, existingAlert.TrailAmount = req.ChangePct, req.TrailAmount
+		}
+
+		// The state reset below drops the running extreme: carry it over
+		// as the starting extreme, or restart from the current price when
+		// the alert now trails the other way
+		if req.ReferencePrice == nil {
+			if !wasTrailing || existingAlert.Direction != trailDirection {
+				existingAlert.ReferencePrice = nil
+			} else if extreme, ok, err := alertstate.Extreme(ctx, alertID); err == nil && ok {
+				existingAlert.ReferencePrice = &extreme
+			}
+		}
+	}
+
+	if err := validateAlertType(ctx, existingAlert); err != nil {
+		logger.Log.Error("Invalid alert",
 			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		writeAlertError(w, err)
+		return
+	}
+
+	if err := validateLifecycle(existingAlert, time.Now()); err != nil {
+		logger.Log.Error("Invalid alert lifecycle",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAlertEscalation(ctx, existingAlert); err != nil {
+		logger.Log.Error("Invalid alert escalation",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
 		)
-		http.Error(w, "At least one threshold (upper or lower) must be specified", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	existingAlert.UpdatedAt = time.Now()
 
 	// Save the updated alert
-	if err := database.UpdateAlert(ctx, existingAlert); err != nil {
+	if err := database.UpdateAlert(ctx, existingAlert, status); err != nil {
 		logger.Log.Error("Failed to update alert",
 			zap.String("trace_id", traceID),
 			zap.String("alert_id", alertID),
@@ -319,6 +715,9 @@ func UpdateAlertHandler(w http.ResponseWriter, r *http.Request, alertID string,
 		return
 	}
 
+	// The recorded sides refer to the old thresholds
+	resetAlertState(ctx, alertID, traceID)
+
 	// Invalidate cache for browse alerts
 	cache.InvalidateByPrefix(ctx, "browse_alerts_", "/alerts", instance)
 
@@ -350,6 +749,8 @@ func DeleteAlertHandler(w http.ResponseWriter, r *http.Request, alertID string,
 		return
 	}
 
+	resetAlertState(ctx, alertID, traceID)
+
 	// Invalidate cache for browse alerts
 	cache.InvalidateByPrefix(ctx, "browse_alerts_", "/alerts", instance)
 
@@ -361,6 +762,565 @@ func DeleteAlertHandler(w http.ResponseWriter, r *http.Request, alertID string,
 	json.NewEncoder(w).Encode(response)
 }
 
+// Limits of the sustain requirements of an alert
+const (
+	maxSustainSeconds = 24 * 60 * 60
+	maxSustainUpdates = 10000
+)
+
+// validateAlertType checks the sustain requirements and the fields required
+// by the alert's type, and fills in defaults, including the reference price
+// of reference_change alerts when none was given
+func validateAlertType(ctx context.Context, alert *models.Alert) error {
+	if alert.SustainSeconds < 0 || alert.SustainSeconds > maxSustainSeconds {
+		return fmt.Errorf("Invalid sustain_seconds: expected 0-%d", maxSustainSeconds)
+	}
+	if alert.SustainUpdates < 0 || alert.SustainUpdates > maxSustainUpdates {
+		return fmt.Errorf("Invalid sustain_updates: expected 0-%d", maxSustainUpdates)
+	}
+
+	if alert.Type == "" && alert.Condition != "" {
+		alert.Type = models.AlertTypeCondition
+		if alert.Interval != "" || alert.OnClose {
+			alert.Type = models.AlertTypeIndicator
+		}
+	}
+	if alert.Type == "" {
+		alert.Type = models.AlertTypeThreshold
+	}
+
+	if alert.Type == models.AlertTypeCondition || alert.Type == models.AlertTypeIndicator {
+		if alert.Condition == "" {
+			return fmt.Errorf("Missing condition: %s alerts need an expression", alert.Type)
+		}
+		if alert.Type == models.AlertTypeIndicator {
+			if alert.Interval == "" {
+				alert.Interval = "1m"
+			}
+			if _, ok := candles.Intervals[alert.Interval]; !ok {
+				return fmt.Errorf("Invalid interval: expected 1m, 5m, 1h or 1d")
+			}
+		} else if alert.Interval != "" || alert.OnClose {
+			return fmt.Errorf("Invalid condition alert: interval and on_close need type indicator")
+		}
+		_, err := expr.Compile(alert.Condition)
+		return err
+	}
+
+	if alert.Type == models.AlertTypeThreshold {
+		if alert.UpperThreshold == nil && alert.LowerThreshold == nil {
+			return fmt.Errorf("At least one threshold (upper or lower) must be specified")
+		}
+		return nil
+	}
+
+	if alert.Type == models.AlertTypeTrailing {
+		return validateTrailing(ctx, alert)
+	}
+
+	if alert.Type == models.AlertTypeSpread {
+		return validateSpread(alert)
+	}
+
+	if alert.Type == models.AlertTypeVolumeSpike {
+		return validateVolumeSpike(alert)
+	}
+
+	if alert.Type == models.AlertTypeLargeTrade {
+		return validateLargeTrade(alert)
+	}
+
+	if alert.ChangePct == nil || *alert.ChangePct <= 0 {
+		return fmt.Errorf("Invalid change_pct: %s alerts need a positive percentage", alert.Type)
+	}
+
+	// Moves re-arm once they fall back below change_pct - hysteresis
+	if alert.Hysteresis >= *alert.ChangePct {
+		return fmt.Errorf("Invalid hysteresis: %s alerts measure it in percentage points, below change_pct", alert.Type)
+	}
+
+	switch alert.Direction {
+	case "", models.DirectionUp, models.DirectionDown:
+	default:
+		return fmt.Errorf("Invalid direction: expected up or down")
+	}
+
+	maxWindow := int(pricewindow.MaxSpan / time.Minute)
+
+	switch alert.Type {
+	case models.AlertTypeWindowChange:
+		if alert.WindowMinutes < 1 || alert.WindowMinutes > maxWindow {
+			return fmt.Errorf("Invalid window_minutes: expected 1-%d", maxWindow)
+		}
+	case models.AlertTypeDrawdown:
+		if alert.WindowMinutes == 0 {
+			alert.WindowMinutes = maxWindow
+		}
+		if alert.WindowMinutes < 1 || alert.WindowMinutes > maxWindow {
+			return fmt.Errorf("Invalid window_minutes: expected 1-%d", maxWindow)
+		}
+		alert.Direction = models.DirectionDown
+	case models.AlertTypeReferenceChange:
+		return defaultReferencePrice(ctx, alert)
+	default:
+		return fmt.Errorf("Invalid type: expected threshold, window_change, reference_change, drawdown, trailing, spread, volume_spike, large_trade, condition or indicator")
+	}
+
+	return nil
+}
+
+// validateTrailing checks a trailing alert: a retrace of either change_pct
+// or trail_amount, and a direction defaulting to down. The starting extreme
+// defaults to the current price.
+func validateTrailing(ctx context.Context, alert *models.Alert) error {
+	if (alert.ChangePct == nil) == (alert.TrailAmount == nil) {
+		return fmt.Errorf("Invalid trailing alert: set exactly one of change_pct and trail_amount")
+	}
+	if alert.ChangePct != nil && *alert.ChangePct <= 0 {
+		return fmt.Errorf("Invalid change_pct: trailing alerts need a positive percentage")
+	}
+	if alert.TrailAmount != nil && *alert.TrailAmount <= 0 {
+		return fmt.Errorf("Invalid trail_amount: must be positive")
+	}
+
+	switch alert.Direction {
+	case "":
+		alert.Direction = models.DirectionDown
+	case models.DirectionUp, models.DirectionDown:
+	default:
+		return fmt.Errorf("Invalid direction: expected up or down")
+	}
+	if alert.Direction == models.DirectionDown && alert.ChangePct != nil && *alert.ChangePct >= 100 {
+		return fmt.Errorf("Invalid change_pct: a retrace from a high must be under 100%%")
+	}
+
+	return defaultReferencePrice(ctx, alert)
+}
+
+// Staleness of the legs of spread alerts, in seconds
+const (
+	defaultStaleSeconds = 60
+	maxStaleSeconds     = 24 * 60 * 60
+)
+
+// validateSpread checks a spread alert and normalizes its second leg, which
+// defaults to the alert's symbol so a cross-exchange spread only needs
+// leg_exchange
+func validateSpread(alert *models.Alert) error {
+	switch alert.Formula {
+	case "":
+		alert.Formula = models.FormulaDifference
+	case models.FormulaRatio, models.FormulaDifference, models.FormulaPercent:
+	default:
+		return fmt.Errorf("Invalid formula: expected ratio, difference or percent")
+	}
+
+	if alert.LegSymbol == "" {
+		alert.LegSymbol = alert.Symbol
+	}
+	legSymbol, ok := normalizeSymbol(alert.LegSymbol)
+	if !ok {
+		return fmt.Errorf("Invalid leg_symbol: expected BASE-QUOTE, e.g. BTC-USD")
+	}
+	legExchange, ok := normalizeExchange(alert.LegExchange)
+	if !ok {
+		return fmt.Errorf("Invalid leg_exchange: expected consolidated or one of %s", strings.Join(exchange.Names(), ", "))
+	}
+	alert.LegSymbol, alert.LegExchange = legSymbol, legExchange
+	if alert.LegSymbol == alert.Symbol && alert.LegExchange == alert.Exchange {
+		return fmt.Errorf("Invalid spread: both legs are %s on %s", alert.Symbol, alert.Exchange)
+	}
+
+	if alert.StaleSeconds == 0 {
+		alert.StaleSeconds = defaultStaleSeconds
+	}
+	if alert.StaleSeconds < 1 || alert.StaleSeconds > maxStaleSeconds {
+		return fmt.Errorf("Invalid stale_seconds: expected 1-%d", maxStaleSeconds)
+	}
+
+	if alert.UpperThreshold == nil && alert.LowerThreshold == nil {
+		return fmt.Errorf("At least one threshold (upper or lower) must be specified")
+	}
+	return nil
+}
+
+// maxCooldown bounds the cooldown an alert may set, in seconds
+const maxCooldown = 24 * 60 * 60
+
+// validateLifecycle checks the mode, cooldown and expiry of an alert. Only
+// times alerts have a number of firings, and an active alert must expire
+// in the future.
+func validateLifecycle(alert *models.Alert, now time.Time) error {
+	switch alert.Mode {
+	case "":
+		alert.Mode = models.ModeRecurring
+	case models.ModeRecurring, models.ModeOnce, models.ModeTimes:
+	default:
+		return fmt.Errorf("Invalid mode: expected recurring, once or times")
+	}
+
+	if alert.Mode == models.ModeTimes {
+		if alert.MaxTriggers < 1 {
+			return fmt.Errorf("Invalid max_triggers: times alerts need a positive number of firings")
+		}
+	} else if alert.MaxTriggers != 0 {
+		return fmt.Errorf("Invalid max_triggers: only times alerts have a number of firings")
+	}
+
+	if alert.Cooldown < models.NoCooldown || alert.Cooldown > maxCooldown {
+		return fmt.Errorf("Invalid cooldown_seconds: expected 0-%d, or %d for none", maxCooldown, models.NoCooldown)
+	}
+
+	if alert.Status == models.StatusActive && alert.ExpiresAt != nil && !alert.ExpiresAt.After(now) {
+		return fmt.Errorf("Invalid expires_at: must be in the future")
+	}
+	return nil
+}
+
+// Default look-backs of volume spike alerts, in minutes
+const (
+	defaultSpikeWindow    = 1
+	defaultBaselineWindow = 60
+)
+
+// validateVolumeSpike checks a volume spike alert: the volume of the last
+// window_minutes against multiplier times its average over the preceding
+// baseline_minutes. Both look-backs must fit in the price window.
+func validateVolumeSpike(alert *models.Alert) error {
+	if alert.Multiplier == nil || *alert.Multiplier <= 0 {
+		return fmt.Errorf("Invalid multiplier: volume_spike alerts need a positive multiplier")
+	}
+	if alert.Hysteresis >= *alert.Multiplier {
+		return fmt.Errorf("Invalid hysteresis: volume_spike alerts measure it in multiples of the baseline, below multiplier")
+	}
+
+	if alert.WindowMinutes == 0 {
+		alert.WindowMinutes = defaultSpikeWindow
+	}
+	if alert.BaselineWindow == 0 {
+		alert.BaselineWindow = defaultBaselineWindow
+	}
+	maxWindow := int(pricewindow.MaxSpan / time.Minute)
+	if alert.WindowMinutes < 1 || alert.BaselineWindow < 1 || alert.WindowMinutes+alert.BaselineWindow > maxWindow {
+		return fmt.Errorf("Invalid window_minutes or baseline_minutes: each at least 1, together at most %d", maxWindow)
+	}
+	return nil
+}
+
+// validateLargeTrade checks a large_trade alert. It fires on single trades,
+// so there is no condition to sustain.
+func validateLargeTrade(alert *models.Alert) error {
+	if alert.MinSize == nil || *alert.MinSize <= 0 {
+		return fmt.Errorf("Invalid min_size: large_trade alerts need a positive trade size")
+	}
+
+	switch alert.TradeSide {
+	case "", models.TradeSideBuy, models.TradeSideSell:
+	default:
+		return fmt.Errorf("Invalid trade_side: expected buy or sell")
+	}
+
+	if alert.SustainSeconds != 0 || alert.SustainUpdates != 0 {
+		return fmt.Errorf("Invalid large_trade alert: sustain_seconds and sustain_updates do not apply")
+	}
+	return nil
+}
+
+// defaultReferencePrice sets the reference price of an alert to the current
+// price when none was given
+func defaultReferencePrice(ctx context.Context, alert *models.Alert) error {
+	if alert.ReferencePrice == nil {
+		point, err := database.GetPriceAt(ctx, alert.Symbol, alert.Exchange, time.Now())
+		if err != nil {
+			return fmt.Errorf("No current price for %s on %s: set reference_price", alert.Symbol, alert.Exchange)
+		}
+		alert.ReferencePrice = &point.Price
+	}
+	if *alert.ReferencePrice <= 0 {
+		return fmt.Errorf("Invalid reference_price: must be positive")
+	}
+	return nil
+}
+
+// trailingState fills in the running extreme and trigger level of a
+// trailing alert. Until price processing has seen a price the extreme is
+// the starting one.
+func trailingState(ctx context.Context, alert *models.Alert) error {
+	if alert.Type != models.AlertTypeTrailing || alert.ReferencePrice == nil {
+		return nil
+	}
+
+	extreme, ok, err := alertstate.Extreme(ctx, alert.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		extreme = *alert.ReferencePrice
+	}
+	level := alertstate.TrailLevel(alert, extreme)
+	alert.TrailingExtreme, alert.TriggerLevel = &extreme, &level
+	return nil
+}
+
+// writeAlertError answers a failed alert validation. Condition errors carry
+// the column they were found at.
+func writeAlertError(w http.ResponseWriter, err error) {
+	var conditionErr *expr.Error
+	if !errors.As(err, &conditionErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := Response{
+		Message: "Invalid condition: " + conditionErr.Error(),
+		Data:    ConditionError{Position: conditionErr.Pos, Error: conditionErr.Msg},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(response)
+}
+
+// resetAlertState clears the crossing state of an alert. A failure is only
+// logged: the stale state at worst suppresses or causes a single firing.
+func resetAlertState(ctx context.Context, alertID, traceID string) {
+	if err := alertstate.Reset(ctx, alertID); err != nil {
+		logger.Log.Warn("Failed to reset alert state",
+			zap.String("trace_id", traceID),
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Status actions on alerts
+const (
+	actionPause  = "pause"  // until resumed
+	actionResume = "resume" // a paused or snoozed alert
+	actionSnooze = "snooze" // pause until a time
+)
+
+// actionDone is the past tense of each status action, for responses
+var actionDone = map[string]string{
+	actionPause:  "paused",
+	actionResume: "resumed",
+	actionSnooze: "snoozed",
+}
+
+func isStatusAction(action string) bool {
+	_, ok := actionDone[action]
+	return ok
+}
+
+// maxSnooze bounds how far ahead an alert can be snoozed
+const maxSnooze = 30 * 24 * time.Hour
+
+// parseSnoozeUntil parses the until parameter of a snooze: an RFC3339 time
+// or a duration from now, such as 1h
+func parseSnoozeUntil(raw string, now time.Time) (time.Time, error) {
+	var until time.Time
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		until = t
+	} else if d, err := time.ParseDuration(raw); err == nil {
+		until = now.Add(d)
+	} else {
+		return time.Time{}, fmt.Errorf("Invalid until: expected an RFC3339 time or a duration such as 1h")
+	}
+
+	if !until.After(now) || until.Sub(now) > maxSnooze {
+		return time.Time{}, fmt.Errorf("Invalid until: must be in the future and at most %s ahead", maxSnooze)
+	}
+	return until, nil
+}
+
+// snoozeUntil returns the end of the snooze of a snooze request, and nil
+// for the other actions
+func snoozeUntil(r *http.Request, action string) (*time.Time, error) {
+	if action != actionSnooze {
+		return nil, nil
+	}
+	until, err := parseSnoozeUntil(r.URL.Query().Get("until"), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &until, nil
+}
+
+// changeAlertStatus runs a status action on the selected alerts and returns
+// the IDs of those it changed. Only active and paused alerts can be paused
+// or snoozed, and only paused ones resumed.
+func changeAlertStatus(ctx context.Context, action string, sel database.AlertSelector, until *time.Time) ([]string, error) {
+	if action == actionResume {
+		return database.ResumeAlerts(ctx, sel, time.Now())
+	}
+	return database.PauseAlerts(ctx, sel, until)
+}
+
+// AlertStatusHandler pauses, resumes or snoozes one alert
+// URL patterns: POST /alerts/{id}/pause, /alerts/{id}/resume, /alerts/{id}/snooze?until=
+func AlertStatusHandler(w http.ResponseWriter, r *http.Request, alertID, action string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "AlertStatusHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	until, err := snoozeUntil(r, action)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changed, err := changeAlertStatus(ctx, action, database.AlertSelector{ID: alertID}, until)
+	if err != nil {
+		logger.Log.Error("Failed to change alert status",
+			zap.String("trace_id", traceID),
+			zap.String("alert_id", alertID),
+			zap.String("action", action),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to change alert status", http.StatusInternalServerError)
+		return
+	}
+
+	alert, err := database.GetAlertByID(ctx, alertID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch alert",
+			zap.String("trace_id", traceID),
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	if len(changed) == 0 {
+		http.Error(w, fmt.Sprintf("Cannot %s an alert that is %s", action, alert.Status), http.StatusConflict)
+		return
+	}
+
+	// Invalidate cache for browse alerts
+	cache.InvalidateByPrefix(ctx, "browse_alerts_", "/alerts", instance)
+
+	response := Response{
+		Message: "Alert " + actionDone[action] + " successfully",
+		Data:    alert,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// BulkStatusResult lists the alerts a bulk status action changed
+type BulkStatusResult struct {
+	AlertIDs []string `json:"alert_ids"`
+}
+
+// BulkAlertStatusHandler pauses, resumes or snoozes the alerts of a user,
+// optionally only those on one symbol. Alerts the action does not apply to,
+// such as triggered ones, are left alone.
+// URL patterns: POST /alerts/pause?user_id=&symbol=, /alerts/resume?..., /alerts/snooze?...&until=
+func BulkAlertStatusHandler(w http.ResponseWriter, r *http.Request, action string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "BulkAlertStatusHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	sel := database.AlertSelector{UserID: r.URL.Query().Get("user_id")}
+	if sel.UserID == "" {
+		http.Error(w, "Missing required query parameter: user_id", http.StatusBadRequest)
+		return
+	}
+	if raw := r.URL.Query().Get("symbol"); raw != "" {
+		symbol, ok := normalizeSymbol(raw)
+		if !ok {
+			http.Error(w, "Invalid symbol: expected BASE-QUOTE, e.g. BTC-USD", http.StatusBadRequest)
+			return
+		}
+		sel.Symbol = symbol
+	}
+
+	until, err := snoozeUntil(r, action)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changed, err := changeAlertStatus(ctx, action, sel, until)
+	if err != nil {
+		logger.Log.Error("Failed to change alert statuses",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", sel.UserID),
+			zap.String("action", action),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to change alert statuses", http.StatusInternalServerError)
+		return
+	}
+
+	// Invalidate cache for browse alerts
+	cache.InvalidateByPrefix(ctx, "browse_alerts_", "/alerts", instance)
+
+	response := Response{
+		Message: fmt.Sprintf("%d alerts %s", len(changed), actionDone[action]),
+		Data:    BulkStatusResult{AlertIDs: changed},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AlertHistoryHandler lists the most recent firings of an alert
+func AlertHistoryHandler(w http.ResponseWriter, r *http.Request, alertID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "AlertHistoryHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, err := database.GetTriggeredAlertsByAlertID(ctx, alertID, limit)
+	if err != nil {
+		logger.Log.Error("Failed to fetch alert history",
+			zap.String("trace_id", traceID),
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch alert history", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Alert history retrieved successfully",
+		Data:    history,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseLimit reads the optional limit query parameter (default 100, max 1000)
+func parseLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return 100, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > 1000 {
+		return 0, fmt.Errorf("Invalid limit: expected 1-1000")
+	}
+	return limit, nil
+}
+
 func generateCacheKey(r *http.Request, prefix string) string {
 	queryParams := r.URL.Query()
 	var keys []string
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pricenotification/internal/expr"
+	"pricenotification/internal/models"
+)
+
+func TestWriteAlertErrorPosition(t *testing.T) {
+	_, err := expr.Compile("price > 1 && prcie < 2")
+	if err == nil {
+		t.Fatal("Compile succeeded, want an error")
+	}
+
+	rec := httptest.NewRecorder()
+	writeAlertError(rec, err)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var body struct {
+		Message string         `json:"message"`
+		Data    ConditionError `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.Position != 14 {
+		t.Errorf("position = %d, want 14", body.Data.Position)
+	}
+	if body.Data.Error == "" || body.Message == "" {
+		t.Errorf("body = %+v, want a message and an error", body)
+	}
+}
+
+func TestValidateSustain(t *testing.T) {
+	upper := 100.0
+	tests := []struct {
+		seconds, updates int
+		ok               bool
+	}{
+		{0, 0, true},
+		{300, 0, true},
+		{0, 5, true},
+		{maxSustainSeconds, maxSustainUpdates, true},
+		{-1, 0, false},
+		{maxSustainSeconds + 1, 0, false},
+		{0, -1, false},
+		{0, maxSustainUpdates + 1, false},
+	}
+
+	for _, tt := range tests {
+		alert := &models.Alert{UpperThreshold: &upper, SustainSeconds: tt.seconds, SustainUpdates: tt.updates}
+		err := validateAlertType(context.Background(), alert)
+		if (err == nil) != tt.ok {
+			t.Errorf("sustain_seconds %d, sustain_updates %d: error %v, want ok %v", tt.seconds, tt.updates, err, tt.ok)
+		}
+	}
+}
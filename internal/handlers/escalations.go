@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"pricenotification/internal/database"
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+type CreateEscalationPolicyRequest struct {
+	Name          string                  `json:"name"`
+	Steps         []models.EscalationStep `json:"steps"`
+	RepeatSeconds int                     `json:"repeat_seconds,omitempty"`
+}
+
+// Bounds of escalation policies
+const (
+	maxEscalationSteps  = 10
+	maxEscalationDelay  = 24 * 60 * 60 // seconds from the firing to a step
+	minEscalationRepeat = 60           // seconds between repeats of the last step
+)
+
+// validateEscalationPolicy checks the steps of a policy: in order of their
+// delay, on known channels, with webhook steps targeting one of the user's
+// webhooks and email steps an optional address
+func validateEscalationPolicy(ctx context.Context, policy *models.EscalationPolicy) error {
+	if policy.Name == "" {
+		return fmt.Errorf("Missing required field: name")
+	}
+	if len(policy.Steps) == 0 || len(policy.Steps) > maxEscalationSteps {
+		return fmt.Errorf("Invalid steps: expected 1-%d steps", maxEscalationSteps)
+	}
+
+	var hooks []*models.Webhook
+	for i := range policy.Steps {
+		step := &policy.Steps[i]
+		if step.AfterSeconds < 0 || step.AfterSeconds > maxEscalationDelay {
+			return fmt.Errorf("Invalid after_seconds of step %d: expected 0-%d", i+1, maxEscalationDelay)
+		}
+		if i > 0 && step.AfterSeconds < policy.Steps[i-1].AfterSeconds {
+			return fmt.Errorf("Invalid steps: steps must be ordered by after_seconds")
+		}
+
+		switch step.Channel {
+		case models.ChannelSSE:
+			if step.Target != "" {
+				return fmt.Errorf("Invalid target of step %d: sse steps have no target", i+1)
+			}
+		case models.ChannelWebhook:
+			if hooks == nil {
+				var err error
+				if hooks, err = database.GetWebhooksByUserID(ctx, policy.UserID); err != nil {
+					return err
+				}
+			}
+			if !slices.ContainsFunc(hooks, func(hook *models.Webhook) bool { return hook.ID == step.Target }) {
+				return fmt.Errorf("Invalid target of step %d: expected the ID of one of the user's webhooks", i+1)
+			}
+		case models.ChannelEmail:
+			if step.Target == "" {
+				break
+			}
+			address, ok := contactValidators[models.ChannelEmail](step.Target)
+			if !ok {
+				return fmt.Errorf("Invalid target of step %d: expected an email address", i+1)
+			}
+			step.Target = address
+		default:
+			return fmt.Errorf("Invalid channel of step %d: expected one of %s", i+1, strings.Join(models.Channels, ", "))
+		}
+	}
+
+	if policy.RepeatSeconds != 0 && (policy.RepeatSeconds < minEscalationRepeat || policy.RepeatSeconds > maxEscalationDelay) {
+		return fmt.Errorf("Invalid repeat_seconds: expected 0 or %d-%d", minEscalationRepeat, maxEscalationDelay)
+	}
+	return nil
+}
+
+// CreateEscalationPolicyHandler registers an escalation policy for a user
+func CreateEscalationPolicyHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "CreateEscalationPolicyHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	var req CreateEscalationPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log.Error("Failed to parse request body",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policy := &models.EscalationPolicy{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Name:          strings.TrimSpace(req.Name),
+		Steps:         req.Steps,
+		RepeatSeconds: req.RepeatSeconds,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if err := validateEscalationPolicy(ctx, policy); err != nil {
+		logger.Log.Error("Invalid escalation policy",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := database.CreateEscalationPolicy(ctx, policy); err != nil {
+		logger.Log.Error("Failed to create escalation policy",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to create escalation policy", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Escalation policy created successfully",
+		Data:    policy,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListEscalationPoliciesHandler lists a user's escalation policies
+func ListEscalationPoliciesHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "ListEscalationPoliciesHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	policies, err := database.GetEscalationPoliciesByUserID(ctx, userID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch escalation policies",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch escalation policies", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Escalation policies retrieved successfully",
+		Data:    policies,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteEscalationPolicyHandler removes one of a user's escalation policies.
+// Policies still used by an alert cannot be deleted.
+func DeleteEscalationPolicyHandler(w http.ResponseWriter, r *http.Request, userID, policyID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "DeleteEscalationPolicyHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	err := database.DeleteEscalationPolicy(ctx, userID, policyID)
+	switch {
+	case errors.Is(err, database.ErrEscalationPolicyNotFound):
+		http.Error(w, "Escalation policy not found", http.StatusNotFound)
+		return
+	case errors.Is(err, database.ErrEscalationPolicyInUse):
+		http.Error(w, "Escalation policy is used by an alert", http.StatusConflict)
+		return
+	case err != nil:
+		logger.Log.Error("Failed to delete escalation policy",
+			zap.String("trace_id", traceID),
+			zap.String("escalation_id", policyID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to delete escalation policy", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Escalation policy deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validateAlertEscalation checks that the escalation policy of an alert, if
+// any, is one of its user's
+func validateAlertEscalation(ctx context.Context, alert *models.Alert) error {
+	if alert.EscalationID == "" {
+		return nil
+	}
+	policy, err := database.GetEscalationPolicyByID(ctx, alert.EscalationID)
+	if errors.Is(err, database.ErrEscalationPolicyNotFound) || (err == nil && policy.UserID != alert.UserID) {
+		return fmt.Errorf("Invalid escalation_id: expected the ID of one of the user's escalation policies")
+	}
+	return err
+}
+
+type AcknowledgeAlertRequest struct {
+	UserID string `json:"user_id"` // owner of the firings, recorded as acknowledged_by
+}
+
+// AcknowledgeResult counts the firings an acknowledgement stopped escalating
+type AcknowledgeResult struct {
+	Acknowledged int `json:"acknowledged"`
+}
+
+// AcknowledgeAlertHandler acknowledges the escalating firings of an alert,
+// which stops their escalation. The alert itself may have been deleted.
+// URL pattern: POST /alerts/{id}/ack
+func AcknowledgeAlertHandler(w http.ResponseWriter, r *http.Request, alertID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "AcknowledgeAlertHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	var req AcknowledgeAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log.Error("Failed to parse request body",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" {
+		http.Error(w, "Missing required field: user_id", http.StatusBadRequest)
+		return
+	}
+
+	acknowledged, err := database.AcknowledgeAlert(ctx, alertID, req.UserID, time.Now().UTC())
+	if err != nil {
+		logger.Log.Error("Failed to acknowledge alert",
+			zap.String("trace_id", traceID),
+			zap.String("alert_id", alertID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to acknowledge alert", http.StatusInternalServerError)
+		return
+	}
+
+	if acknowledged == 0 {
+		http.Error(w, "Alert has no unacknowledged firings of the user", http.StatusConflict)
+		return
+	}
+
+	response := Response{
+		Message: "Alert acknowledged successfully",
+		Data:    AcknowledgeResult{Acknowledged: acknowledged},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
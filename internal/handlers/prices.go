@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"pricenotification/internal/cache"
+	"pricenotification/internal/candles"
+	"pricenotification/internal/database"
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// maxHistoryPoints bounds a single history response
+const maxHistoryPoints = 5000
+
+// PriceHistory is the payload of GET /prices/{symbol}/history. Exactly one
+// of Prices (raw interval) or Candles is set.
+type PriceHistory struct {
+	Symbol   string               `json:"symbol"`
+	Exchange string               `json:"exchange"`
+	Interval string               `json:"interval"`
+	From     time.Time            `json:"from"`
+	To       time.Time            `json:"to"`
+	Prices   []*models.PricePoint `json:"prices,omitempty"`
+	Candles  []*models.Candle     `json:"candles,omitempty"`
+}
+
+// PricesHandler handles price queries
+// URL patterns: /prices/{symbol}/latest, /prices/{symbol}/history
+func PricesHandler(w http.ResponseWriter, r *http.Request, instance string) {
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 4 || pathParts[2] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol, ok := normalizeSymbol(pathParts[2])
+	if !ok {
+		http.Error(w, "Invalid symbol: expected BASE-QUOTE, e.g. BTC-USD", http.StatusBadRequest)
+		return
+	}
+
+	switch pathParts[3] {
+	case "latest":
+		LatestPriceHandler(w, r, symbol, instance)
+	case "history":
+		PriceHistoryHandler(w, r, symbol, instance)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// LatestPriceHandler returns the latest price of a symbol, or the last price
+// at or before the optional `at` time
+func LatestPriceHandler(w http.ResponseWriter, r *http.Request, symbol string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "LatestPriceHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	exchangeName, ok := normalizeExchange(r.URL.Query().Get("exchange"))
+	if !ok {
+		http.Error(w, "Invalid exchange", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	at := now
+	if raw := r.URL.Query().Get("at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "Invalid at: expected RFC3339 time", http.StatusBadRequest)
+			return
+		}
+		at = parsed.UTC()
+	}
+
+	// Live prices change every second; settled historical ones never do
+	ttl := 2 * time.Second
+	if at.Before(now.Add(-time.Minute)) {
+		ttl = time.Hour
+	}
+	cacheKey := generateCacheKey(r, "price_latest_"+symbol+"_")
+	endpoint := "/prices/latest"
+
+	cached, err := cache.GetCache(ctx, cacheKey, endpoint, instance)
+	if err == nil && cached != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(cached))
+		return
+	}
+
+	point, err := database.GetPriceAt(ctx, symbol, exchangeName, at)
+	if err != nil {
+		if errors.Is(err, database.ErrPriceNotFound) {
+			http.Error(w, "No price recorded for "+symbol, http.StatusNotFound)
+			return
+		}
+		logger.Log.Error("Failed to fetch price",
+			zap.String("trace_id", traceID),
+			zap.String("symbol", symbol),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch price", http.StatusInternalServerError)
+		return
+	}
+
+	writeCachedJSON(w, r, Response{
+		Message: "Price retrieved successfully",
+		Data:    point,
+	}, cacheKey, ttl, endpoint, instance)
+}
+
+// PriceHistoryHandler returns prices of a symbol between `from` and `to`
+// (default: the last 24h) as raw points or candles of `interval`
+func PriceHistoryHandler(w http.ResponseWriter, r *http.Request, symbol string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "PriceHistoryHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+	query := r.URL.Query()
+
+	exchangeName, ok := normalizeExchange(query.Get("exchange"))
+	if !ok {
+		http.Error(w, "Invalid exchange", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	to, err := parseTimeParam(query.Get("to"), now)
+	if err != nil {
+		http.Error(w, "Invalid to: expected RFC3339 time", http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), to.Add(-24*time.Hour))
+	if err != nil {
+		http.Error(w, "Invalid from: expected RFC3339 time", http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = defaultHistoryInterval(to.Sub(from))
+	}
+	if interval != "raw" {
+		duration, ok := candles.Intervals[interval]
+		if !ok {
+			http.Error(w, "Invalid interval: expected raw, 1m, 5m, 1h or 1d", http.StatusBadRequest)
+			return
+		}
+		if to.Sub(from)/duration > maxHistoryPoints {
+			http.Error(w, "Range too large for interval; use a coarser interval", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Ranges that ended a while ago are immutable and can be cached longer
+	ttl := 5 * time.Second
+	if to.Before(now.Add(-time.Minute)) {
+		ttl = 10 * time.Minute
+	}
+	cacheKey := generateCacheKey(r, "price_history_"+symbol+"_")
+	endpoint := "/prices/history"
+
+	cached, err := cache.GetCache(ctx, cacheKey, endpoint, instance)
+	if err == nil && cached != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(cached))
+		return
+	}
+
+	history := PriceHistory{
+		Symbol:   symbol,
+		Exchange: exchangeName,
+		Interval: interval,
+		From:     from,
+		To:       to,
+	}
+
+	var dbErr error
+	if interval == "raw" {
+		history.Prices, dbErr = database.GetPriceHistory(ctx, symbol, exchangeName, from, to, maxHistoryPoints+1)
+		if dbErr == nil && len(history.Prices) > maxHistoryPoints {
+			http.Error(w, "Range too large for raw prices; use a candle interval", http.StatusBadRequest)
+			return
+		}
+	} else {
+		history.Candles, dbErr = database.GetCandles(ctx, symbol, exchangeName, interval, from, to)
+	}
+
+	if dbErr != nil {
+		logger.Log.Error("Failed to fetch price history",
+			zap.String("trace_id", traceID),
+			zap.String("symbol", symbol),
+			zap.Error(dbErr),
+		)
+		http.Error(w, "Failed to fetch price history", http.StatusInternalServerError)
+		return
+	}
+
+	writeCachedJSON(w, r, Response{
+		Message: "Price history retrieved successfully",
+		Data:    history,
+	}, cacheKey, ttl, endpoint, instance)
+}
+
+// defaultHistoryInterval picks the finest candle interval that keeps a range
+// well under maxHistoryPoints
+func defaultHistoryInterval(span time.Duration) string {
+	switch {
+	case span <= 6*time.Hour:
+		return "1m"
+	case span <= 7*24*time.Hour:
+		return "5m"
+	case span <= 180*24*time.Hour:
+		return "1h"
+	default:
+		return "1d"
+	}
+}
+
+// parseTimeParam parses an optional RFC3339 query parameter
+func parseTimeParam(raw string, fallback time.Time) (time.Time, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	return t.UTC(), err
+}
+
+// writeCachedJSON encodes response, stores it in the cache and writes it
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, response Response, cacheKey string, ttl time.Duration, endpoint, instance string) {
+	respBytes, err := json.Marshal(response)
+	if err != nil {
+		logger.Log.Error("Failed to encode JSON response", zap.Error(err))
+		http.Error(w, "Failed to encode JSON response", http.StatusInternalServerError)
+		return
+	}
+
+	if cacheErr := cache.SetCache(r.Context(), cacheKey, string(respBytes), ttl, endpoint, instance); cacheErr != nil {
+		logger.Log.Warn("Failed to store response in cache",
+			zap.String("cache_key", cacheKey),
+			zap.Error(cacheErr),
+		)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respBytes)
+}
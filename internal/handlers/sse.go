@@ -3,88 +3,156 @@ package handlers
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"pricenotification/internal/cache"
 	"pricenotification/internal/logger"
 
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 // AlertMessage represents an alert that will be streamed
 type AlertMessage struct {
+	ID        string  `json:"id,omitempty"` // Redis stream entry ID, sent as the SSE event id
+	AlertID   string  `json:"alert_id,omitempty"`
 	UserID    string  `json:"user_id"`
 	Symbol    string  `json:"symbol"`
+	Exchange  string  `json:"exchange,omitempty"`
+	Price     float64 `json:"price,omitempty"`
 	Threshold float64 `json:"threshold"`
 	Triggered string  `json:"triggered"` // "above" or "below"
+	Size      float64 `json:"size,omitempty"`
 	Timestamp string  `json:"timestamp"`
 }
 
-// SSE Clients
+// SSE Clients, indexed by user ID so an alert only reaches its owner. Each
+// client's channel maps to one that is closed when the client falls behind.
 var (
-	clients = make(map[chan AlertMessage]bool)
-	mu      sync.Mutex
+	clients     = make(map[string]map[chan AlertMessage]chan struct{})
+	clientCount int
+	mu          sync.Mutex
 )
 
-// Redis channel name for alerts
-const alertsChannel = "price_alerts"
+// Redis stream carrying alerts. Entries are kept so clients can replay what
+// they missed while disconnected; the stream is capped at roughly
+// alertsStreamMaxLen entries.
+const (
+	alertsStream       = "price_alerts"
+	alertsStreamField  = "alert"
+	alertsStreamMaxLen = 100000
+)
 
-// Initialize Redis subscription for alerts
-var alertSubscriber *cache.RedisSubscriber
+// replayBatchSize is the number of entries fetched per XRANGE during replay
+const replayBatchSize = 500
 
 // InitSSE initializes the SSE system
 func InitSSE() {
-	// Create a Redis subscriber for alerts
-	var err error
-	alertSubscriber, err = cache.NewRedisSubscriber(alertsChannel)
+	// Only deliver alerts added from now on; older ones are replayed per
+	// client on request via Last-Event-ID
+	lastID, err := cache.LastStreamID(context.Background(), alertsStream)
 	if err != nil {
-		logger.Log.Error("Failed to create Redis subscriber", zap.Error(err))
-		return
+		logger.Log.Error("Failed to read alert stream position", zap.Error(err))
+		lastID = "$"
 	}
 
 	// Start listening for published alerts
-	go listenForAlerts()
+	go listenForAlerts(lastID)
 }
 
-// listenForAlerts continuously listens for alerts from Redis and broadcasts to clients
-func listenForAlerts() {
-	logger.Log.Info("Starting to listen for alerts from Redis")
+// listenForAlerts continuously reads new alerts from the Redis stream and broadcasts to clients
+func listenForAlerts(lastID string) {
+	logger.Log.Info("Starting to listen for alerts from Redis stream", zap.String("from_id", lastID))
 	
 	for {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		msg, err := alertSubscriber.ReceiveMessage(ctx)
-		cancel()
-		
+		messages, err := cache.ReadStream(context.Background(), alertsStream, lastID, 100, 5*time.Second)
 		if err != nil {
-			logger.Log.Error("Error receiving message from Redis", zap.Error(err))
+			logger.Log.Error("Error reading alert stream from Redis", zap.Error(err))
 			time.Sleep(1 * time.Second) // Wait before retry
 			continue
 		}
 
-		var alert AlertMessage
-		if err := json.Unmarshal([]byte(msg.Payload), &alert); err != nil {
-			logger.Log.Error("Error unmarshaling alert message", zap.Error(err))
-			continue
+		for _, msg := range messages {
+			lastID = msg.ID
+
+			alert, err := decodeStreamAlert(msg)
+			if err != nil {
+				logger.Log.Error("Error unmarshaling alert message", zap.Error(err))
+				continue
+			}
+
+			logger.Log.Info("Received alert from Redis", 
+				zap.String("id", alert.ID),
+				zap.String("symbol", alert.Symbol),
+				zap.String("triggered", alert.Triggered))
+				
+			broadcastToClients(alert)
 		}
+	}
+}
+
+// decodeStreamAlert parses a stream entry, stamping it with its entry ID
+func decodeStreamAlert(msg redis.XMessage) (AlertMessage, error) {
+	var alert AlertMessage
+	payload, _ := msg.Values[alertsStreamField].(string)
+	if err := json.Unmarshal([]byte(payload), &alert); err != nil {
+		return alert, err
+	}
+	alert.ID = msg.ID
+	return alert, nil
+}
+
+// streamUserID identifies the caller of the alert stream. An authenticating
+// proxy may set X-User-ID; otherwise the user_id query parameter is used,
+// since EventSource cannot send custom headers. When SSE_TOKEN_SECRET is
+// set, the caller must also present token = hex(HMAC-SHA256(secret, user_id)).
+func streamUserID(r *http.Request) (string, error) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		userID = r.URL.Query().Get("user_id")
+	}
+	if userID == "" {
+		return "", fmt.Errorf("missing user_id")
+	}
 
-		// Broadcast to all connected clients
-		logger.Log.Info("Received alert from Redis", 
-			zap.String("symbol", alert.Symbol),
-			zap.String("triggered", alert.Triggered))
-			
-		broadcastToClients(alert)
+	secret := os.Getenv("SSE_TOKEN_SECRET")
+	if secret == "" {
+		return userID, nil
 	}
+
+	token, err := hex.DecodeString(r.URL.Query().Get("token"))
+	if err != nil {
+		return "", fmt.Errorf("invalid token")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	if !hmac.Equal(token, mac.Sum(nil)) {
+		return "", fmt.Errorf("invalid token")
+	}
+	return userID, nil
 }
 
-// StreamAlertsHandler handles SSE connections
+// StreamAlertsHandler handles SSE connections, streaming only the caller's alerts
 func StreamAlertsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 	w.Header().Set("Access-Control-Allow-Methods", "GET")
+
+	userID, err := streamUserID(r)
+	if err != nil {
+		logger.Log.Warn("Rejected SSE client", zap.Error(err))
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -96,101 +164,190 @@ func StreamAlertsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	clientChan := make(chan AlertMessage, 10)
+	dropped := make(chan struct{})
 
 	mu.Lock()
-	clients[clientChan] = true
-	clientCount := len(clients)
+	if clients[userID] == nil {
+		clients[userID] = make(map[chan AlertMessage]chan struct{})
+	}
+	clients[userID][clientChan] = dropped
+	clientCount++
+	total := clientCount
 	mu.Unlock()
 
-	logger.Log.Info("New SSE client connected", zap.Int("total_clients", clientCount))
+	logger.Log.Info("New SSE client connected",
+		zap.String("user_id", userID),
+		zap.Int("total_clients", total))
 
 	defer func() {
 		mu.Lock()
-		delete(clients, clientChan)
-		clientCount := len(clients)
+		delete(clients[userID], clientChan)
+		if len(clients[userID]) == 0 {
+			delete(clients, userID)
+		}
+		clientCount--
+		total := clientCount
 		mu.Unlock()
-		close(clientChan)
-		logger.Log.Info("SSE client disconnected", zap.Int("total_clients", clientCount))
+		logger.Log.Info("SSE client disconnected",
+			zap.String("user_id", userID),
+			zap.Int("total_clients", total))
 	}()
 
+	// Replay what the client missed. The client is registered first, so
+	// alerts arriving during replay are queued and deduplicated below.
+	lastSentID := ""
+	if lastEventID := requestLastEventID(r); lastEventID != "" {
+		lastSentID = replayAlerts(r.Context(), w, flusher, userID, lastEventID)
+	}
+
 	// Send heartbeats to keep connection alive
-	go func() {
-		heartbeatTicker := time.NewTicker(15 * time.Second)
-		defer heartbeatTicker.Stop()
-		
-		for {
-			select {
-			case <-heartbeatTicker.C:
-				select {
-				case clientChan <- AlertMessage{Timestamp: time.Now().Format(time.RFC3339)}:
-					// Heartbeat sent successfully
-				default:
-					// Channel is blocked or closed, exit goroutine
-					return
-				}
-			case <-r.Context().Done():
-				// Request context done, exit goroutine
-				return
+	heartbeatTicker := time.NewTicker(15 * time.Second)
+	defer heartbeatTicker.Stop()
+
+	// Stream events to client until it disconnects
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-dropped:
+			// Ending the stream makes the client reconnect with
+			// Last-Event-ID and replay what it missed
+			return
+		case <-heartbeatTicker.C:
+			// Heartbeats carry no id so they don't move the client's position
+			writeSSEEvent(w, flusher, AlertMessage{Timestamp: time.Now().Format(time.RFC3339)})
+		case alert := <-clientChan:
+			if lastSentID != "" && cache.CompareStreamIDs(alert.ID, lastSentID) <= 0 {
+				continue
 			}
+			writeSSEEvent(w, flusher, alert)
+			lastSentID = alert.ID
 		}
-	}()
+	}
+}
 
-	// Stream events to client
-	for alert := range clientChan {
-		alertData, err := json.Marshal(alert)
+// requestLastEventID returns the position a reconnecting client resumes
+// from. Browsers send Last-Event-ID on automatic reconnects; pages that
+// recreate their EventSource pass it as the last_event_id query parameter.
+func requestLastEventID(r *http.Request) string {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	if !cache.ValidStreamID(id) {
+		return ""
+	}
+	return id
+}
+
+// replayAlerts writes every alert of userID after lastEventID still held in
+// the stream and returns the ID of the last entry examined
+func replayAlerts(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, userID, lastEventID string) string {
+	cursor := lastEventID
+	replayed := 0
+
+	for {
+		messages, err := cache.RangeStreamAfter(ctx, alertsStream, cursor, replayBatchSize)
 		if err != nil {
-			logger.Log.Error("Failed to marshal alert data", zap.Error(err))
-			continue
+			logger.Log.Error("Failed to replay alerts",
+				zap.String("user_id", userID),
+				zap.String("last_event_id", lastEventID),
+				zap.Error(err))
+			return cursor
+		}
+
+		for _, msg := range messages {
+			cursor = msg.ID
+
+			alert, err := decodeStreamAlert(msg)
+			if err != nil || alert.UserID != userID {
+				continue
+			}
+			writeSSEEvent(w, flusher, alert)
+			replayed++
+		}
+
+		if len(messages) < replayBatchSize {
+			logger.Log.Info("Replayed missed alerts",
+				zap.String("user_id", userID),
+				zap.String("last_event_id", lastEventID),
+				zap.Int("replayed", replayed))
+			return cursor
 		}
-		
-		fmt.Fprintf(w, "data: %s\n\n", alertData)
-		flusher.Flush()
 	}
 }
 
-// broadcastToClients sends alert to all connected SSE clients
+// writeSSEEvent writes one event, with an id line when the alert has one
+func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, alert AlertMessage) {
+	alertData, err := json.Marshal(alert)
+	if err != nil {
+		logger.Log.Error("Failed to marshal alert data", zap.Error(err))
+		return
+	}
+
+	if alert.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", alert.ID)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", alertData)
+	flusher.Flush()
+}
+
+// broadcastToClients sends alert to the connected SSE clients of its user
 func broadcastToClients(alert AlertMessage) {
 	mu.Lock()
 	defer mu.Unlock()
 
+	userClients := clients[alert.UserID]
+
 	logger.Log.Info("Broadcasting alert to clients", 
-		zap.Int("client_count", len(clients)),
+		zap.Int("client_count", len(userClients)),
+		zap.String("user_id", alert.UserID),
 		zap.String("symbol", alert.Symbol))
 
-	if len(clients) == 0 {
-		logger.Log.Warn("No SSE clients available! Skipping alert broadcast.")
+	if len(userClients) == 0 {
+		// Nothing is lost: the alert stays in the stream for replay
+		logger.Log.Info("No SSE clients for user, alert kept for replay",
+			zap.String("user_id", alert.UserID))
 		return
 	}
 
-	for clientChan := range clients {
+	for clientChan, dropped := range userClients {
 		select {
 		case clientChan <- alert:
 			// Alert sent successfully
 		default:
-			logger.Log.Warn("Alert dropped due to slow client")
+			// The alert stays in the stream: disconnect the client so it
+			// replays from its last event instead of silently missing it
+			logger.Log.Warn("Disconnecting slow SSE client",
+				zap.String("user_id", alert.UserID),
+				zap.String("id", alert.ID))
+			delete(userClients, clientChan)
+			close(dropped)
 		}
 	}
 }
 
-// BroadcastAlert publishes alert to Redis for distribution
-func BroadcastAlert(alert AlertMessage) {
-	logger.Log.Info("Publishing alert to Redis", 
+// BroadcastAlert appends alert to the Redis stream for distribution
+func BroadcastAlert(alert AlertMessage) error {
+	logger.Log.Info("Publishing alert to Redis stream", 
 		zap.String("symbol", alert.Symbol),
 		zap.String("user_id", alert.UserID))
 		
 	alertJSON, err := json.Marshal(alert)
 	if err != nil {
 		logger.Log.Error("Failed to marshal alert", zap.Error(err))
-		return
+		return err
 	}
 
-	// Publish to Redis channel
-	err = cache.PublishMessage(alertsChannel, string(alertJSON))
+	// Append to the Redis stream; Redis assigns the event ID
+	id, err := cache.AppendToStream(context.Background(), alertsStream, alertsStreamField, string(alertJSON), alertsStreamMaxLen)
 	if err != nil {
 		logger.Log.Error("Failed to publish alert to Redis", zap.Error(err))
-		return
+		return err
 	}
 
 	logger.Log.Info("Alert published to Redis successfully", 
+		zap.String("id", id),
 		zap.String("symbol", alert.Symbol))
-}
\ No newline at end of file
+	return nil
+}
@@ -0,0 +1,662 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"pricenotification/internal/database"
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+	"pricenotification/internal/notify"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+type CreateWebhookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type UpdateContactRequest struct {
+	Address string `json:"address"`
+}
+
+type UpdatePreferencesRequest struct {
+	Channels        []string                            `json:"channels"`
+	Addresses       map[string]string                   `json:"addresses,omitempty"`
+	SymbolOverrides map[string]models.SymbolPreferences `json:"symbol_overrides,omitempty"`
+	QuietHours      *models.QuietHours                  `json:"quiet_hours,omitempty"`
+	MaxPerHour      int                                 `json:"max_per_hour"`
+}
+
+// contactValidators normalizes and validates addresses per contact channel
+var contactValidators = map[string]func(string) (string, bool){
+	models.ChannelEmail: func(address string) (string, bool) {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return "", false
+		}
+		return parsed.Address, true
+	},
+}
+
+// UsersHandler handles per-user resources
+// URL patterns: /users/{id}/notifications[/{triggeredID}], /users/{id}/webhooks[/{webhookID}],
+// /users/{id}/contacts[/{channel}], /users/{id}/preferences,
+// /users/{id}/escalation-policies[/{policyID}]
+func UsersHandler(w http.ResponseWriter, r *http.Request, instance string) {
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 4 || pathParts[2] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID := pathParts[2]
+
+	switch pathParts[3] {
+	case "notifications":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if len(pathParts) > 4 && pathParts[4] != "" {
+			NotificationDeliveryHandler(w, r, userID, pathParts[4], instance)
+			return
+		}
+		UserNotificationsHandler(w, r, userID, instance)
+	case "webhooks":
+		if len(pathParts) > 4 && pathParts[4] != "" {
+			if r.Method != http.MethodDelete {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			DeleteWebhookHandler(w, r, userID, pathParts[4], instance)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			ListWebhooksHandler(w, r, userID, instance)
+		case http.MethodPost:
+			CreateWebhookHandler(w, r, userID, instance)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "escalation-policies":
+		if len(pathParts) > 4 && pathParts[4] != "" {
+			if r.Method != http.MethodDelete {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			DeleteEscalationPolicyHandler(w, r, userID, pathParts[4], instance)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			ListEscalationPoliciesHandler(w, r, userID, instance)
+		case http.MethodPost:
+			CreateEscalationPolicyHandler(w, r, userID, instance)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "contacts":
+		if len(pathParts) < 5 || pathParts[4] == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			ListContactsHandler(w, r, userID, instance)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			UpdateContactHandler(w, r, userID, pathParts[4], instance)
+		case http.MethodDelete:
+			DeleteContactHandler(w, r, userID, pathParts[4], instance)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "preferences":
+		switch r.Method {
+		case http.MethodGet:
+			GetPreferencesHandler(w, r, userID, instance)
+		case http.MethodPut:
+			UpdatePreferencesHandler(w, r, userID, instance)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// UserNotificationsHandler lists a user's most recent triggered alerts,
+// optionally only those since an RFC3339 `since` time
+func UserNotificationsHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "UserNotificationsHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	since, err := parseTimeParam(r.URL.Query().Get("since"), time.Time{})
+	if err != nil {
+		http.Error(w, "Invalid since: expected RFC3339 time", http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := database.GetTriggeredAlertsByUserID(ctx, userID, since, limit)
+	if err != nil {
+		logger.Log.Error("Failed to fetch notifications",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Notifications retrieved successfully",
+		Data:    notifications,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ChannelDelivery is the outbox entry of one channel with its attempts
+type ChannelDelivery struct {
+	*models.OutboxEntry
+	History []*models.DeliveryAttempt `json:"history"`
+}
+
+// NotificationDelivery is the delivery audit trail of one triggered alert
+type NotificationDelivery struct {
+	*models.TriggeredAlert
+	Deliveries []*ChannelDelivery `json:"deliveries"`
+}
+
+// NotificationDeliveryHandler returns a triggered alert with the outcome of
+// every delivery channel and each attempt made
+func NotificationDeliveryHandler(w http.ResponseWriter, r *http.Request, userID, triggeredID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "NotificationDeliveryHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	triggered, err := database.GetTriggeredAlertByID(ctx, triggeredID)
+	if err != nil || triggered.UserID != userID {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	entries, err := database.GetOutboxEntries(ctx, triggeredID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch deliveries",
+			zap.String("trace_id", traceID),
+			zap.String("triggered_alert_id", triggeredID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	attempts, err := database.GetDeliveryAttempts(ctx, triggeredID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch delivery attempts",
+			zap.String("trace_id", traceID),
+			zap.String("triggered_alert_id", triggeredID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	byEntry := make(map[string]*ChannelDelivery, len(entries))
+	delivery := NotificationDelivery{TriggeredAlert: triggered, Deliveries: []*ChannelDelivery{}}
+	for _, entry := range entries {
+		d := &ChannelDelivery{OutboxEntry: entry, History: []*models.DeliveryAttempt{}}
+		byEntry[entry.ID] = d
+		delivery.Deliveries = append(delivery.Deliveries, d)
+	}
+	for _, attempt := range attempts {
+		if d, ok := byEntry[attempt.OutboxID]; ok {
+			d.History = append(d.History, attempt)
+		}
+	}
+
+	response := Response{
+		Message: "Notification retrieved successfully",
+		Data:    delivery,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateWebhookHandler registers a webhook for a user. The signing secret is
+// generated when not supplied and is only ever returned in this response.
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "CreateWebhookHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log.Error("Failed to parse request body",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "Invalid url: expected an absolute http(s) URL", http.StatusBadRequest)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	hook := &models.Webhook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		URL:       target.String(),
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := database.CreateWebhook(ctx, hook); err != nil {
+		logger.Log.Error("Failed to create webhook",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Webhook created successfully",
+		Data:    hook,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListWebhooksHandler lists a user's webhooks without their secrets
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "ListWebhooksHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	hooks, err := database.GetWebhooksByUserID(ctx, userID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch webhooks",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+
+	response := Response{
+		Message: "Webhooks retrieved successfully",
+		Data:    hooks,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteWebhookHandler removes one of a user's webhooks
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request, userID, webhookID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "DeleteWebhookHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	if err := database.DeleteWebhook(ctx, userID, webhookID); err != nil {
+		logger.Log.Error("Failed to delete webhook",
+			zap.String("trace_id", traceID),
+			zap.String("webhook_id", webhookID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Webhook deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListContactsHandler lists a user's per-channel addresses
+func ListContactsHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "ListContactsHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	contacts, err := database.GetUserContacts(ctx, userID)
+	if err != nil {
+		logger.Log.Error("Failed to fetch contacts",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch contacts", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Contacts retrieved successfully",
+		Data:    contacts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateContactHandler sets a user's address on a channel
+func UpdateContactHandler(w http.ResponseWriter, r *http.Request, userID, channel string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "UpdateContactHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	validate, ok := contactValidators[channel]
+	if !ok {
+		http.Error(w, "Unsupported contact channel: "+channel, http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log.Error("Failed to parse request body",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	address, ok := validate(req.Address)
+	if !ok {
+		http.Error(w, "Invalid address for channel "+channel, http.StatusBadRequest)
+		return
+	}
+
+	contact := &models.UserContact{
+		UserID:    userID,
+		Channel:   channel,
+		Address:   address,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := database.UpsertUserContact(ctx, contact); err != nil {
+		logger.Log.Error("Failed to save contact",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to save contact", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Contact saved successfully",
+		Data:    contact,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteContactHandler removes a user's address on a channel
+func DeleteContactHandler(w http.ResponseWriter, r *http.Request, userID, channel string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "DeleteContactHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	if err := database.DeleteUserContact(ctx, userID, channel); err != nil {
+		if errors.Is(err, database.ErrContactNotFound) {
+			http.Error(w, "Contact not found", http.StatusNotFound)
+			return
+		}
+		logger.Log.Error("Failed to delete contact",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to delete contact", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Contact deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetPreferencesHandler returns a user's notification preferences, or the
+// defaults when none were saved
+func GetPreferencesHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "GetPreferencesHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	prefs, err := database.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, database.ErrPreferencesNotFound) {
+		prefs = notify.DefaultPreferences(userID)
+		contacts, cerr := database.GetUserContacts(ctx, userID)
+		err = cerr
+		if err == nil {
+			prefs.Addresses = make(map[string]string, len(contacts))
+			for _, c := range contacts {
+				prefs.Addresses[c.Channel] = c.Address
+			}
+		}
+	}
+	if err != nil {
+		logger.Log.Error("Failed to fetch preferences",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Preferences retrieved successfully",
+		Data:    prefs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdatePreferencesHandler replaces a user's notification preferences
+func UpdatePreferencesHandler(w http.ResponseWriter, r *http.Request, userID string, instance string) {
+	ctx := r.Context()
+	tracer := otel.Tracer("real-time-notification")
+	ctx, span := tracer.Start(ctx, "UpdatePreferencesHandler")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	var req UpdatePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log.Error("Failed to parse request body",
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := validatePreferences(userID, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := database.UpsertNotificationPreferences(ctx, prefs); err != nil {
+		logger.Log.Error("Failed to save preferences",
+			zap.String("trace_id", traceID),
+			zap.String("user_id", userID),
+			zap.Error(err),
+		)
+		http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+
+	response := Response{
+		Message: "Preferences saved successfully",
+		Data:    prefs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validatePreferences checks a preferences update and normalizes its
+// channels, addresses and symbols
+func validatePreferences(userID string, req UpdatePreferencesRequest) (*models.NotificationPreferences, error) {
+	channels, err := validateChannels(req.Channels)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make(map[string]string, len(req.Addresses))
+	for channel, address := range req.Addresses {
+		validate, ok := contactValidators[channel]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported contact channel: %s", channel)
+		}
+		normalized, ok := validate(address)
+		if !ok {
+			return nil, fmt.Errorf("Invalid address for channel %s", channel)
+		}
+		addresses[channel] = normalized
+	}
+
+	overrides := make(map[string]models.SymbolPreferences, len(req.SymbolOverrides))
+	for symbol, override := range req.SymbolOverrides {
+		normalized, ok := normalizeSymbol(symbol)
+		if !ok {
+			return nil, fmt.Errorf("Invalid symbol in symbol_overrides: %s", symbol)
+		}
+		override.Channels, err = validateChannels(override.Channels)
+		if err != nil {
+			return nil, err
+		}
+		overrides[normalized] = override
+	}
+
+	if q := req.QuietHours; q != nil {
+		if _, err := notify.ParseClock(q.Start); err != nil {
+			return nil, fmt.Errorf("Invalid quiet_hours.start: %v", err)
+		}
+		if _, err := notify.ParseClock(q.End); err != nil {
+			return nil, fmt.Errorf("Invalid quiet_hours.end: %v", err)
+		}
+		if q.Timezone == "" {
+			q.Timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			return nil, fmt.Errorf("Invalid quiet_hours.timezone: %s", q.Timezone)
+		}
+	}
+
+	if req.MaxPerHour < 0 {
+		return nil, errors.New("Invalid max_per_hour: must not be negative")
+	}
+
+	return &models.NotificationPreferences{
+		UserID:          userID,
+		Channels:        channels,
+		Addresses:       addresses,
+		SymbolOverrides: overrides,
+		QuietHours:      req.QuietHours,
+		MaxPerHour:      req.MaxPerHour,
+		UpdatedAt:       time.Now().UTC(),
+	}, nil
+}
+
+// validateChannels lower-cases and deduplicates channel names
+func validateChannels(channels []string) ([]string, error) {
+	seen := make(map[string]bool, len(channels))
+	normalized := []string{}
+	for _, channel := range channels {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !slices.Contains(models.Channels, channel) {
+			return nil, fmt.Errorf("Unknown channel: %q", channel)
+		}
+		if !seen[channel] {
+			seen[channel] = true
+			normalized = append(normalized, channel)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,349 @@
+// Package indicators computes technical indicators over candle closes per
+// price stream and interval. Each closed candle updates the state of every
+// indicator in constant time, and values that include the open candle are
+// derived from that state, so they are cheap enough to read on every trade.
+package indicators
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"pricenotification/internal/candles"
+	"pricenotification/internal/expr"
+	"pricenotification/internal/models"
+)
+
+// MaxPeriod is the longest period an indicator may use, in candles
+const MaxPeriod = expr.MaxPeriod
+
+// Series is the indicator state of one stream at one candle interval. It
+// is safe for concurrent use.
+type Series struct {
+	mu       sync.Mutex
+	interval time.Duration
+	closes   []float64 // closes of the last closed candles, oldest first
+	openTime time.Time // open time of the open candle, zero before any price
+	price    float64   // last price of the open candle
+
+	windows map[int]*window
+	emas    map[int]*ema
+	rsis    map[int]*rsi
+}
+
+// NewSeries creates an empty series of candles of the given length
+func NewSeries(interval time.Duration) *Series {
+	return &Series{
+		interval: interval,
+		windows:  make(map[int]*window),
+		emas:     make(map[int]*ema),
+		rsis:     make(map[int]*rsi),
+	}
+}
+
+// Seed replays candles, oldest first, e.g. from the database after a
+// restart. The last one becomes the open candle.
+func (s *Series) Seed(candles []*models.Candle) {
+	for _, c := range candles {
+		s.Add(c.Close, c.OpenTime)
+	}
+}
+
+// Add records a trade at time at and reports whether it closed the open
+// candle. Trades older than the open candle arrive too late and are
+// dropped, as by the candle service.
+func (s *Series) Add(price float64, at time.Time) (closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	openTime := at.Truncate(s.interval)
+	switch {
+	case s.openTime.IsZero():
+		s.openTime = openTime
+	case openTime.Before(s.openTime):
+		return false
+	case openTime.After(s.openTime):
+		s.close(s.price)
+		s.openTime = openTime
+		closed = true
+	}
+	s.price = price
+	return closed
+}
+
+// close appends the close of a candle and updates every indicator
+func (s *Series) close(c float64) {
+	s.closes = append(s.closes, c)
+	for _, w := range s.windows {
+		w.push(s.closes)
+	}
+	for _, e := range s.emas {
+		e.push(c)
+	}
+	for _, r := range s.rsis {
+		r.push(c)
+	}
+
+	// RSI needs one close more than its period
+	if len(s.closes) > MaxPeriod+1 {
+		s.closes = s.closes[1:]
+	}
+}
+
+// Close returns the close of the last closed candle
+func (s *Series) Close() (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.closes) == 0 {
+		return 0, false
+	}
+	return s.closes[len(s.closes)-1], true
+}
+
+// The indicator values below are computed over the last n closed candles
+// or, when live, over the last n-1 closed candles and the open one, whose
+// close is the last price so far
+
+// SMA returns the simple moving average of the last n closes
+func (s *Series) SMA(n int, live bool) (float64, bool) {
+	middle, _, ok := s.Bands(n, live)
+	return middle, ok
+}
+
+// Bands returns the average and the standard deviation of the last n
+// closes, the middle and the half-width per deviation of Bollinger bands
+func (s *Series) Bands(n int, live bool) (middle, stddev float64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.valid(n, live) || len(s.closes) < n-liveCandles(live) {
+		return 0, 0, false
+	}
+	w := s.windows[n]
+	if w == nil {
+		w = &window{n: n}
+		for i := range s.closes {
+			w.push(s.closes[:i+1])
+		}
+		s.windows[n] = w
+	}
+
+	sum, sumSq := w.sum, w.sumSq
+	if live {
+		sum += s.price
+		sumSq += s.price * s.price
+		if len(s.closes) >= n {
+			oldest := s.closes[len(s.closes)-n]
+			sum -= oldest
+			sumSq -= oldest * oldest
+		}
+	}
+	middle = sum / float64(n)
+	variance := sumSq/float64(n) - middle*middle
+	return middle, math.Sqrt(max(variance, 0)), true
+}
+
+// EMA returns the exponential moving average of period n, seeded with the
+// simple average of its first n closes
+func (s *Series) EMA(n int, live bool) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.valid(n, live) {
+		return 0, false
+	}
+	e := s.emas[n]
+	if e == nil {
+		e = &ema{n: n}
+		for _, c := range s.closes {
+			e.push(c)
+		}
+		s.emas[n] = e
+	}
+
+	switch {
+	case live && e.seen == n-1:
+		// The open candle completes the seed average
+		return (e.value + s.price) / float64(n), true
+	case e.seen < n:
+		return 0, false
+	case live:
+		return e.next(s.price), true
+	}
+	return e.value, true
+}
+
+// RSI returns the relative strength index of period n, with Wilder's
+// smoothing of the average gain and loss
+func (s *Series) RSI(n int, live bool) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.valid(n, live) {
+		return 0, false
+	}
+	r := s.rsis[n]
+	if r == nil {
+		r = &rsi{n: n}
+		for _, c := range s.closes {
+			r.push(c)
+		}
+		s.rsis[n] = r
+	}
+
+	switch {
+	case live && r.seen == n-1 && r.hasLast:
+		// The open candle completes the seed averages
+		g, l := change(r.last, s.price)
+		return strength(r.avgGain+g/float64(n), r.avgLoss+l/float64(n)), true
+	case r.seen < n:
+		return 0, false
+	case live:
+		return strength(r.next(s.price)), true
+	}
+	return strength(r.avgGain, r.avgLoss), true
+}
+
+// valid checks a period, and that a live value has an open candle
+func (s *Series) valid(n int, live bool) bool {
+	return n >= 1 && n <= MaxPeriod && (!live || !s.openTime.IsZero())
+}
+
+// liveCandles is the number of open candles a value includes
+func liveCandles(live bool) int {
+	if live {
+		return 1
+	}
+	return 0
+}
+
+// window keeps the sum and the sum of squares of the last n closes
+type window struct {
+	n          int
+	sum, sumSq float64
+}
+
+// push adds the last of closes and drops the one leaving the window
+func (w *window) push(closes []float64) {
+	c := closes[len(closes)-1]
+	w.sum += c
+	w.sumSq += c * c
+	if len(closes) > w.n {
+		old := closes[len(closes)-1-w.n]
+		w.sum -= old
+		w.sumSq -= old * old
+	}
+}
+
+// ema is an exponential moving average
+type ema struct {
+	n     int
+	seen  int
+	value float64
+}
+
+func (e *ema) push(c float64) {
+	e.seen++
+	switch {
+	case e.seen < e.n:
+		e.value += c
+	case e.seen == e.n:
+		e.value = (e.value + c) / float64(e.n)
+	default:
+		e.value = e.next(c)
+	}
+}
+
+// next is the average after a close of c
+func (e *ema) next(c float64) float64 {
+	k := 2 / float64(e.n+1)
+	return e.value + k*(c-e.value)
+}
+
+// rsi keeps Wilder's average gain and loss between closes
+type rsi struct {
+	n                int
+	seen             int // changes seen
+	last             float64
+	hasLast          bool
+	avgGain, avgLoss float64
+}
+
+func (r *rsi) push(c float64) {
+	if !r.hasLast {
+		r.last, r.hasLast = c, true
+		return
+	}
+	r.seen++
+	if r.seen <= r.n {
+		// The first averages are simple ones
+		gain, loss := change(r.last, c)
+		r.avgGain += gain / float64(r.n)
+		r.avgLoss += loss / float64(r.n)
+	} else {
+		r.avgGain, r.avgLoss = r.next(c)
+	}
+	r.last = c
+}
+
+// next is the average gain and loss after a close of c
+func (r *rsi) next(c float64) (gain, loss float64) {
+	g, l := change(r.last, c)
+	n := float64(r.n)
+	return (r.avgGain*(n-1) + g) / n, (r.avgLoss*(n-1) + l) / n
+}
+
+// change splits a move into a gain and a loss
+func change(from, to float64) (gain, loss float64) {
+	if to > from {
+		return to - from, 0
+	}
+	return 0, from - to
+}
+
+// strength turns average gain and loss into an RSI from 0 to 100
+func strength(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// seriesKey identifies the series of one stream at one interval
+type seriesKey struct {
+	symbol   string
+	exchange string
+	interval string
+}
+
+// Tracker holds the series of every stream and interval. It is safe for
+// concurrent use.
+type Tracker struct {
+	mu     sync.Mutex
+	series map[seriesKey]*Series
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{series: make(map[seriesKey]*Series)}
+}
+
+// Get returns the series of a stream at a candle interval (1m, 5m, 1h or
+// 1d), creating it when needed. created reports a new series, which the
+// caller may want to seed.
+func (t *Tracker) Get(symbol, exchange, interval string) (s *Series, created bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := seriesKey{symbol, exchange, interval}
+	if s, ok := t.series[key]; ok {
+		return s, false
+	}
+	s = NewSeries(candles.Intervals[interval])
+	t.series[key] = s
+	return s, true
+}
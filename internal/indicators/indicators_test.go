@@ -0,0 +1,196 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// feed adds one trade per closing price, one minute apart, so every trade
+// but the last closes the candle before it. The last one stays open.
+func feed(s *Series, prices ...float64) {
+	for i, p := range prices {
+		s.Add(p, start.Add(time.Duration(i)*time.Minute))
+	}
+}
+
+// closedSeries returns a series in which every price closed a candle
+func closedSeries(prices ...float64) *Series {
+	s := NewSeries(time.Minute)
+	feed(s, prices...)
+	s.Add(0, start.Add(time.Duration(len(prices))*time.Minute))
+	return s
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// Reference values from the worked examples in StockCharts' ChartSchool
+var (
+	emaCloses = []float64{22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+		22.15, 22.39, 22.38, 22.61, 23.36}
+	// 10-period EMA after each close, from the 10th on
+	emaWant = []float64{22.22, 22.21, 22.24, 22.27, 22.33, 22.52}
+
+	rsiCloses = []float64{44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+		45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22}
+	// 14-period RSI after each close, from the 15th on. ChartSchool rounds
+	// the average gain and loss, which raises its values by about 0.07.
+	rsiWant = []float64{70.53, 66.32, 66.55, 69.41, 66.36}
+)
+
+func TestEMAReference(t *testing.T) {
+	if _, ok := closedSeries(emaCloses[:9]...).EMA(10, false); ok {
+		t.Error("EMA(10) available after 9 closes")
+	}
+
+	for i, want := range emaWant {
+		got, ok := closedSeries(emaCloses[:10+i]...).EMA(10, false)
+		if !ok || !near(got, want, 0.005) {
+			t.Errorf("EMA(10) after %d closes = %.4f, %v; want %.2f", 10+i, got, ok, want)
+		}
+	}
+}
+
+func TestRSIReference(t *testing.T) {
+	for i, want := range rsiWant {
+		got, ok := closedSeries(rsiCloses[:15+i]...).RSI(14, false)
+		if !ok || !near(got, want, 0.1) {
+			t.Errorf("RSI(14) after %d closes = %.4f, %v; want %.2f", 15+i, got, ok, want)
+		}
+	}
+}
+
+func TestSMAAndBands(t *testing.T) {
+	s := NewSeries(time.Minute)
+	feed(s, 1, 2, 3, 4, 5, 6, 100) // closes 1-6, open candle at 100
+
+	if got, ok := s.SMA(5, false); !ok || got != 4 {
+		t.Errorf("SMA(5) = %v, %v; want 4 over closes 2-6", got, ok)
+	}
+	middle, stddev, ok := s.Bands(5, false)
+	if !ok || middle != 4 || !near(stddev, math.Sqrt(2), 1e-9) {
+		t.Errorf("Bands(5) = %v, %v, %v; want 4, sqrt(2)", middle, stddev, ok)
+	}
+
+	// Live values replace the oldest close with the open candle
+	if got, ok := s.SMA(5, true); !ok || got != (3+4+5+6+100)/5.0 {
+		t.Errorf("live SMA(5) = %v, %v; want %v", got, ok, (3+4+5+6+100)/5.0)
+	}
+	if got, _ := s.Close(); got != 6 {
+		t.Errorf("Close() = %v, want 6", got)
+	}
+}
+
+func TestFirstBar(t *testing.T) {
+	s := NewSeries(time.Minute)
+	if _, ok := s.SMA(1, true); ok {
+		t.Error("live SMA(1) available before any price")
+	}
+
+	feed(s, 42)
+	if _, ok := s.Close(); ok {
+		t.Error("Close() available before any candle closed")
+	}
+	if _, ok := s.SMA(1, false); ok {
+		t.Error("closed SMA(1) available before any candle closed")
+	}
+	// The open candle alone is a full live window of one
+	for name, value := range map[string]func(int, bool) (float64, bool){"SMA": s.SMA, "EMA": s.EMA} {
+		if got, ok := value(1, true); !ok || got != 42 {
+			t.Errorf("live %s(1) = %v, %v; want 42", name, got, ok)
+		}
+	}
+	if _, ok := s.RSI(1, true); ok {
+		t.Error("live RSI(1) available without a previous close")
+	}
+
+	feed(s, 42, 43)
+	if got, ok := s.RSI(1, true); !ok || got != 100 {
+		t.Errorf("live RSI(1) after a rise = %v, %v; want 100", got, ok)
+	}
+}
+
+func TestWindowNotFull(t *testing.T) {
+	s := NewSeries(time.Minute)
+	feed(s, 1, 2, 3) // closes 1, 2; open candle at 3
+
+	if _, ok := s.SMA(3, false); ok {
+		t.Error("closed SMA(3) available after 2 closes")
+	}
+	if _, ok := s.EMA(3, false); ok {
+		t.Error("closed EMA(3) available after 2 closes")
+	}
+	if _, ok := s.SMA(4, true); ok {
+		t.Error("live SMA(4) available with 2 closes and the open candle")
+	}
+
+	// Two closes and the open candle fill a live window of three
+	if got, ok := s.SMA(3, true); !ok || got != 2 {
+		t.Errorf("live SMA(3) = %v, %v; want 2", got, ok)
+	}
+	if got, ok := s.EMA(3, true); !ok || got != 2 {
+		t.Errorf("live EMA(3) = %v, %v; want the seed average 2", got, ok)
+	}
+	if got, ok := s.RSI(2, true); !ok || got != 100 {
+		t.Errorf("live RSI(2) = %v, %v; want 100", got, ok)
+	}
+	if _, ok := s.RSI(3, true); ok {
+		t.Error("live RSI(3) available with 2 changes")
+	}
+}
+
+// TestLiveMatchesClosed checks that the live value over an open candle is
+// the value once that candle closes at the same price, for every period
+// from before the window is full to long after
+func TestLiveMatchesClosed(t *testing.T) {
+	const n = 14
+	for k := 1; k < len(rsiCloses); k++ {
+		s := NewSeries(time.Minute)
+		feed(s, rsiCloses[:k+1]...) // the k-th close is the open candle
+
+		type value func(int, bool) (float64, bool)
+		live := map[string]value{"SMA": s.SMA, "EMA": s.EMA, "RSI": s.RSI}
+		liveValues := make(map[string]float64)
+		liveOK := make(map[string]bool)
+		for name, f := range live {
+			liveValues[name], liveOK[name] = f(n, true)
+		}
+
+		s.Add(0, start.Add(time.Duration(k+1)*time.Minute))
+		closed := map[string]value{"SMA": s.SMA, "EMA": s.EMA, "RSI": s.RSI}
+		for name, f := range closed {
+			got, ok := f(n, false)
+			if ok != liveOK[name] || !near(got, liveValues[name], 1e-9) {
+				t.Errorf("%s(%d) with %d candles: live %v, %v; closed %v, %v",
+					name, n, k+1, liveValues[name], liveOK[name], got, ok)
+			}
+		}
+	}
+}
+
+func TestOnClose(t *testing.T) {
+	s := NewSeries(time.Minute)
+	if closed := s.Add(10, start); closed {
+		t.Error("first trade closed a candle")
+	}
+	if closed := s.Add(11, start.Add(30*time.Second)); closed {
+		t.Error("trade within the open candle closed it")
+	}
+	if closed := s.Add(12, start.Add(time.Minute)); !closed {
+		t.Error("trade in the next minute did not close the open candle")
+	}
+	if got, _ := s.Close(); got != 11 {
+		t.Errorf("Close() = %v, want the last price of the closed candle, 11", got)
+	}
+	// Late trades are dropped
+	if closed := s.Add(99, start.Add(30*time.Second)); closed {
+		t.Error("late trade closed a candle")
+	}
+	if got, _ := s.SMA(1, true); got != 12 {
+		t.Errorf("live SMA(1) = %v, want 12; the late trade must be dropped", got)
+	}
+}
@@ -9,8 +9,314 @@ type Alert struct {
 	ID             string     `json:"id" db:"id"`
 	UserID         string     `json:"user_id" db:"user_id"`
 	Symbol         string     `json:"symbol" db:"symbol"`
+	Exchange       string     `json:"exchange" db:"exchange"`
+	Type           string     `json:"type" db:"type"`
 	UpperThreshold *float64   `json:"upper_threshold,omitempty" db:"upper_threshold"`
 	LowerThreshold *float64   `json:"lower_threshold,omitempty" db:"lower_threshold"`
+	ChangePct      *float64   `json:"change_pct,omitempty" db:"change_pct"`           // move that fires a change alert, in percent
+	WindowMinutes  int        `json:"window_minutes,omitempty" db:"window_minutes"`   // look-back of window_change and drawdown alerts
+	Direction      string     `json:"direction,omitempty" db:"direction"`             // up, down, or empty for either
+	ReferencePrice *float64   `json:"reference_price,omitempty" db:"reference_price"` // base of reference_change alerts, starting extreme of trailing ones
+	TrailAmount    *float64   `json:"trail_amount,omitempty" db:"trail_amount"`       // retrace that fires a trailing alert, in price units
+	Formula        string     `json:"formula,omitempty" db:"formula"`                 // ratio, difference or percent of spread alerts
+	LegSymbol      string     `json:"leg_symbol,omitempty" db:"leg_symbol"`           // second leg of spread alerts
+	LegExchange    string     `json:"leg_exchange,omitempty" db:"leg_exchange"`       // exchange of the second leg
+	StaleSeconds   int        `json:"stale_seconds,omitempty" db:"stale_seconds"`     // age after which a leg's price is too old to use
+	Multiplier     *float64   `json:"multiplier,omitempty" db:"multiplier"`           // volume over the baseline average that fires a volume spike
+	BaselineWindow int        `json:"baseline_minutes,omitempty" db:"baseline_minutes"` // look-back of the volume baseline, in minutes
+	MinSize        *float64   `json:"min_size,omitempty" db:"min_size"`               // smallest trade that fires a large_trade alert
+	TradeSide      string     `json:"trade_side,omitempty" db:"trade_side"`           // buy, sell, or empty for either
+	Condition      string     `json:"condition,omitempty" db:"condition"`             // expression of condition and indicator alerts
+	Interval       string     `json:"interval,omitempty" db:"candle_interval"`        // candle interval of indicator alerts
+	OnClose        bool       `json:"on_close,omitempty" db:"on_close"`               // evaluate indicator alerts on candle closes only
+	Hysteresis     float64    `json:"hysteresis,omitempty" db:"hysteresis"`           // re-arm band in the unit of the level: price, change_pct points or multiplier
+	SustainSeconds int        `json:"sustain_seconds,omitempty" db:"sustain_seconds"` // how long the condition must hold before firing
+	SustainUpdates int        `json:"sustain_updates,omitempty" db:"sustain_updates"` // consecutive updates the condition must hold for
+	Mode           string     `json:"mode" db:"mode"`                                 // once, recurring or times
+	Cooldown       int        `json:"cooldown_seconds,omitempty" db:"cooldown"`       // minimum time between firings, in seconds; 0 for the service default, NoCooldown for none
+	MaxTriggers    int        `json:"max_triggers,omitempty" db:"max_triggers"`       // firings of times alerts
+	TriggerCount   int        `json:"trigger_count" db:"trigger_count"`               // firings so far
+	Status         string     `json:"status" db:"status"`                             // active, triggered, paused or expired
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`           // when an active alert expires
+	SnoozedUntil   *time.Time `json:"snoozed_until,omitempty" db:"snoozed_until"`     // when a snoozed, paused alert resumes
+	EscalationID   string     `json:"escalation_id,omitempty" db:"escalation_id"`     // policy that re-notifies each firing until acknowledged
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
-}
\ No newline at end of file
+
+	// Live state of trailing alerts, kept by price processing
+	TrailingExtreme *float64 `json:"trailing_extreme,omitempty" db:"-"` // highest (lowest) price since creation
+	TriggerLevel    *float64 `json:"trigger_level,omitempty" db:"-"`    // price the alert fires at
+}
+
+// Alert types
+const (
+	// AlertTypeThreshold fires when the price crosses an absolute level
+	AlertTypeThreshold = "threshold"
+	// AlertTypeWindowChange fires when the price moves ChangePct percent
+	// from the low or high of the last WindowMinutes
+	AlertTypeWindowChange = "window_change"
+	// AlertTypeReferenceChange fires when the price moves ChangePct percent
+	// from ReferencePrice, by default the price when the alert was created
+	AlertTypeReferenceChange = "reference_change"
+	// AlertTypeDrawdown fires when the price drops ChangePct percent from
+	// the high of the last WindowMinutes (24 hours by default)
+	AlertTypeDrawdown = "drawdown"
+	// AlertTypeCondition fires when Condition, an expression in the alert
+	// condition language, becomes true
+	AlertTypeCondition = "condition"
+	// AlertTypeIndicator is a condition alert whose indicator functions
+	// (sma, ema, rsi, bb_*) run over candles of Interval rather than 1m,
+	// evaluated per trade or, with OnClose, when a candle closes
+	AlertTypeIndicator = "indicator"
+	// AlertTypeVolumeSpike fires when the volume of the last WindowMinutes
+	// reaches Multiplier times its average over the preceding BaselineWindow
+	AlertTypeVolumeSpike = "volume_spike"
+	// AlertTypeLargeTrade fires on every trade of at least MinSize, on one
+	// venue or, for the consolidated stream, on any venue
+	AlertTypeLargeTrade = "large_trade"
+	// AlertTypeTrailing fires when the price retraces ChangePct percent or
+	// TrailAmount from its highest price since creation, or rises that much
+	// from its lowest with Direction up
+	AlertTypeTrailing = "trailing"
+	// AlertTypeSpread fires when a value derived from the latest prices of
+	// two legs, Symbol on Exchange and LegSymbol on LegExchange, crosses a
+	// threshold
+	AlertTypeSpread = "spread"
+)
+
+// Formulas of spread alerts, over the first leg a and the second leg b
+const (
+	FormulaRatio      = "ratio"      // a / b
+	FormulaDifference = "difference" // a - b
+	FormulaPercent    = "percent"    // (a - b) / b, in percent
+)
+
+// Directions of a price move
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Alert modes
+const (
+	// ModeRecurring alerts fire again after every cooldown
+	ModeRecurring = "recurring"
+	// ModeOnce alerts fire once and are then triggered
+	ModeOnce = "once"
+	// ModeTimes alerts fire MaxTriggers times and are then triggered
+	ModeTimes = "times"
+)
+
+// NoCooldown is the Cooldown of an alert that may fire on every update,
+// where 0 stands for the service default
+const NoCooldown = -1
+
+// Alert statuses. Only active alerts are evaluated.
+const (
+	StatusActive    = "active"
+	StatusTriggered = "triggered" // once and times alerts that used up their firings
+	StatusPaused    = "paused"    // until resumed, or until SnoozedUntil
+	StatusExpired   = "expired"   // past ExpiresAt
+)
+
+// Alert change operations published on every alert write
+const (
+	AlertCreated = "created"
+	AlertUpdated = "updated"
+	AlertDeleted = "deleted"
+)
+
+// AlertChange describes a single write to the alerts table
+type AlertChange struct {
+	Op      string `json:"op"`
+	AlertID string `json:"alert_id"`
+	Symbol  string `json:"symbol"`
+}
+
+// PriceUpdate is the standardized trade event published on the price.updates topic
+type PriceUpdate struct {
+	Exchange  string  `json:"exchange"`
+	Symbol    string  `json:"symbol"`
+	Price     float64 `json:"price"`
+	Size      float64 `json:"size,omitempty"` // traded size; for consolidated prices, the volume since the last one
+	Side      string  `json:"side,omitempty"` // side of the taker, buy or sell, when the venue reports it
+	Timestamp string  `json:"timestamp"`
+}
+
+// Taker sides of a trade
+const (
+	TradeSideBuy  = "buy"
+	TradeSideSell = "sell"
+)
+
+// ConsolidatedExchange is the PriceUpdate.Exchange value of cross-venue
+// prices published on the price.consolidated topic
+const ConsolidatedExchange = "consolidated"
+
+// Candle is an OHLCV bar for one symbol on one exchange over a fixed interval
+type Candle struct {
+	Symbol     string    `json:"symbol" db:"symbol"`
+	Exchange   string    `json:"exchange" db:"exchange"`
+	Interval   string    `json:"interval" db:"timeframe"`
+	OpenTime   time.Time `json:"open_time" db:"open_time"`
+	Open       float64   `json:"open" db:"open"`
+	High       float64   `json:"high" db:"high"`
+	Low        float64   `json:"low" db:"low"`
+	Close      float64   `json:"close" db:"close"`
+	Volume     float64   `json:"volume" db:"volume"`
+	TradeCount int64     `json:"trade_count" db:"trade_count"`
+}
+
+// PricePoint is a single stored price observation
+type PricePoint struct {
+	Symbol   string    `json:"symbol" db:"symbol"`
+	Exchange string    `json:"exchange" db:"exchange"`
+	Price    float64   `json:"price" db:"price"`
+	Size     float64   `json:"size,omitempty" db:"size"`
+	Time     time.Time `json:"time" db:"traded_at"`
+}
+
+// Delivery statuses of a triggered alert
+const (
+	DeliveryPending    = "pending"
+	DeliveryDelivered  = "delivered"
+	DeliveryFailed     = "failed"
+	DeliverySuppressed = "suppressed" // no channel was due, e.g. during quiet hours
+)
+
+// TriggeredAlert records one firing of an alert. Records outlive the alert
+// itself so past notifications can always be audited.
+type TriggeredAlert struct {
+	ID             string    `json:"id" db:"id"`
+	AlertID        string    `json:"alert_id" db:"alert_id"`
+	UserID         string    `json:"user_id" db:"user_id"`
+	Symbol         string    `json:"symbol" db:"symbol"`
+	Exchange       string    `json:"exchange" db:"exchange"`
+	Price          float64   `json:"price" db:"price"`
+	Threshold      float64   `json:"threshold" db:"threshold"`
+	Direction      string    `json:"direction" db:"direction"`
+	Size           float64   `json:"size,omitempty" db:"size"` // size of the trade that fired it, or window volume of volume spikes
+	TriggeredAt    time.Time `json:"triggered_at" db:"triggered_at"`
+	DeliveryStatus string    `json:"delivery_status" db:"delivery_status"`
+
+	// Escalation state, when the alert has an escalation policy
+	EscalationID     string     `json:"escalation_id,omitempty" db:"escalation_id"`
+	EscalationStep   int        `json:"escalation_step,omitempty" db:"escalation_step"`       // steps sent so far, repeats included
+	NextEscalationAt *time.Time `json:"next_escalation_at,omitempty" db:"next_escalation_at"` // nil once acknowledged or done
+	AcknowledgedAt   *time.Time `json:"acknowledged_at,omitempty" db:"acknowledged_at"`
+	AcknowledgedBy   string     `json:"acknowledged_by,omitempty" db:"acknowledged_by"`
+}
+
+// EscalationPolicy re-notifies each firing of an alert on a schedule until
+// the alert is acknowledged, e.g. SSE at once, email after 5 minutes and an
+// on-call webhook after 15
+type EscalationPolicy struct {
+	ID            string           `json:"id" db:"id"`
+	UserID        string           `json:"user_id" db:"user_id"`
+	Name          string           `json:"name" db:"name"`
+	Steps         []EscalationStep `json:"steps" db:"steps"`                             // ordered by AfterSeconds
+	RepeatSeconds int              `json:"repeat_seconds,omitempty" db:"repeat_seconds"` // repeat of the last step, 0 for none
+	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
+}
+
+// EscalationStep is one notification of an escalation policy
+type EscalationStep struct {
+	AfterSeconds int    `json:"after_seconds"` // delay after the firing
+	Channel      string `json:"channel"`
+	Target       string `json:"target,omitempty"` // webhook ID, or email address instead of the user's
+}
+
+// Webhook is a user-registered HTTP endpoint that receives triggered alerts
+type Webhook struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// WebhookDeadLetter is a webhook delivery that exhausted its retries
+type WebhookDeadLetter struct {
+	ID               string    `json:"id" db:"id"`
+	WebhookID        string    `json:"webhook_id" db:"webhook_id"`
+	TriggeredAlertID string    `json:"triggered_alert_id" db:"triggered_alert_id"`
+	Payload          string    `json:"payload" db:"payload"`
+	Attempts         int       `json:"attempts" db:"attempts"`
+	LastError        string    `json:"last_error" db:"last_error"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// Notification channels
+const (
+	ChannelSSE     = "sse"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// UserContact is the address a user is reached at on one channel
+type UserContact struct {
+	UserID    string    `json:"user_id" db:"user_id"`
+	Channel   string    `json:"channel" db:"channel"`
+	Address   string    `json:"address" db:"address"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Outbox entry statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead"
+)
+
+// OutboxEntry is one pending delivery of a triggered alert on one channel.
+// Entries are written in the same transaction as the triggered alert and
+// consumed by the dispatcher.
+type OutboxEntry struct {
+	ID               string    `json:"id" db:"id"`
+	TriggeredAlertID string    `json:"triggered_alert_id" db:"triggered_alert_id"`
+	Channel          string    `json:"channel" db:"channel"`
+	Target           string    `json:"target,omitempty" db:"target"` // webhook ID or email address
+	Status           string    `json:"status" db:"status"`
+	Attempts         int       `json:"attempts" db:"attempts"`
+	NextAttemptAt    time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError        string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// DeliveryAttempt is the audit record of one dispatcher attempt
+type DeliveryAttempt struct {
+	OutboxID    string    `json:"outbox_id" db:"outbox_id"`
+	Attempt     int       `json:"attempt" db:"attempt"`
+	Success     bool      `json:"success" db:"success"`
+	Error       string    `json:"error,omitempty" db:"error"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+}
+
+// Channels lists every notification channel
+var Channels = []string{ChannelSSE, ChannelWebhook, ChannelEmail}
+
+// NotificationPreferences controls how and when a user is notified
+type NotificationPreferences struct {
+	UserID          string                       `json:"user_id" db:"user_id"`
+	Channels        []string                     `json:"channels" db:"channels"` // enabled channels
+	Addresses       map[string]string            `json:"addresses,omitempty"`    // per-channel addresses, kept in user_contacts
+	SymbolOverrides map[string]SymbolPreferences `json:"symbol_overrides,omitempty" db:"symbol_overrides"`
+	QuietHours      *QuietHours                  `json:"quiet_hours,omitempty"`
+	MaxPerHour      int                          `json:"max_per_hour" db:"max_per_hour"` // 0 means unlimited
+	UpdatedAt       time.Time                    `json:"updated_at" db:"updated_at"`
+}
+
+// SymbolPreferences replaces the enabled channels for one symbol
+type SymbolPreferences struct {
+	Channels []string `json:"channels"`
+	Muted    bool     `json:"muted,omitempty"`
+}
+
+// QuietHours is a daily local time range, e.g. 22:00 to 07:00, during which
+// only in-app (SSE) notifications are sent
+type QuietHours struct {
+	Start    string `json:"start" db:"quiet_start"` // HH:MM
+	End      string `json:"end" db:"quiet_end"`     // HH:MM
+	Timezone string `json:"timezone" db:"timezone"` // IANA name, e.g. Europe/Berlin
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a consecutive-failure circuit breaker for one endpoint. After
+// threshold failures in a row it opens and rejects calls for cooldown, then
+// lets a single trial call through (half-open); success closes it again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trialing  bool
+}
+
+// allow reports whether a call may proceed now
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trialing {
+		return false
+	}
+	b.trialing = true
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed call
+func (b *breaker) record(success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialing = false
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
+
+// breakers holds one breaker per endpoint key
+type breakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	byKey map[string]*breaker
+}
+
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	return &breakers{threshold: threshold, cooldown: cooldown, byKey: make(map[string]*breaker)}
+}
+
+func (bs *breakers) get(key string) *breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	b, ok := bs.byKey[key]
+	if !ok {
+		b = &breaker{threshold: bs.threshold, cooldown: bs.cooldown}
+		bs.byKey[key] = b
+	}
+	return b
+}
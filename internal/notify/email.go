@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"go.uber.org/zap"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// defaultTemplate renders alert kinds that have no templates of their own
+const defaultTemplate = "default"
+
+// EmailConfig holds SMTP settings
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// EmailConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD
+// and SMTP_FROM. The defaults target a local MailHog catcher.
+func EmailConfigFromEnv() EmailConfig {
+	cfg := EmailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		Port:     1025,
+	}
+	if cfg.Host == "" {
+		cfg.Host = "localhost"
+	}
+	if port, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil {
+		cfg.Port = port
+	}
+	if cfg.From == "" {
+		cfg.From = "alerts@localhost"
+	}
+	return cfg
+}
+
+// EmailData is the data passed to email templates
+type EmailData struct {
+	Alert       *models.TriggeredAlert
+	Source      string
+	TriggeredAt string
+}
+
+var templateFuncs = map[string]interface{}{
+	"price": func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) },
+}
+
+// EmailSender renders and sends alert emails over SMTP
+type EmailSender struct {
+	cfg  EmailConfig
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// NewEmailSender parses the embedded templates. Each alert kind has a
+// <kind>.txt.tmpl defining the "subject" block and the plain-text body, and
+// a matching <kind>.html.tmpl.
+func NewEmailSender(cfg EmailConfig) (*EmailSender, error) {
+	s := &EmailSender{
+		cfg:  cfg,
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := "templates/" + name
+		switch {
+		case strings.HasSuffix(name, ".txt.tmpl"):
+			t, err := texttemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS, path)
+			if err != nil {
+				return nil, err
+			}
+			s.text[strings.TrimSuffix(name, ".txt.tmpl")] = t
+		case strings.HasSuffix(name, ".html.tmpl"):
+			t, err := htmltemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS, path)
+			if err != nil {
+				return nil, err
+			}
+			s.html[strings.TrimSuffix(name, ".html.tmpl")] = t
+		}
+	}
+
+	if s.text[defaultTemplate] == nil || s.html[defaultTemplate] == nil {
+		return nil, fmt.Errorf("missing %s email templates", defaultTemplate)
+	}
+	return s, nil
+}
+
+// Send emails a triggered alert of the given kind to address
+func (s *EmailSender) Send(address, kind string, triggered *models.TriggeredAlert) error {
+	subject, textBody, htmlBody, err := s.render(kind, triggered)
+	if err != nil {
+		return err
+	}
+
+	msg, err := buildMessage(s.cfg.From, address, subject, textBody, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	if err := smtp.SendMail(addr, auth, s.cfg.From, []string{address}, msg); err != nil {
+		logger.Log.Error("Failed to send alert email",
+			zap.String("triggered_alert_id", triggered.ID),
+			zap.String("smtp", addr),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	logger.Log.Info("Alert email sent",
+		zap.String("triggered_alert_id", triggered.ID),
+		zap.String("kind", kind),
+	)
+	return nil
+}
+
+// render executes the templates of kind, falling back to the default ones
+func (s *EmailSender) render(kind string, triggered *models.TriggeredAlert) (string, string, string, error) {
+	textTmpl, ok := s.text[kind]
+	if !ok {
+		textTmpl = s.text[defaultTemplate]
+	}
+	htmlTmpl, ok := s.html[kind]
+	if !ok {
+		htmlTmpl = s.html[defaultTemplate]
+	}
+
+	data := EmailData{
+		Alert:       triggered,
+		Source:      triggered.Exchange,
+		TriggeredAt: triggered.TriggeredAt.UTC().Format(time.RFC1123),
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return "", "", "", err
+	}
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return "", "", "", err
+	}
+
+	return strings.TrimSpace(subject.String()), strings.TrimSpace(text.String()), html.String(), nil
+}
+
+// buildMessage assembles a multipart/alternative MIME message
+func buildMessage(from, to, subject, textBody, htmlBody string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", textBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	} {
+		pw, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+// Escalate returns the steps of policy due at now for a firing at firedAt,
+// of which sent steps were already sent, together with the new count of
+// sent steps and when the next one is due. Past its last step a policy
+// repeats that step every RepeatSeconds; repeats missed by a late caller
+// are sent once. The next time is nil when no step is left.
+func Escalate(policy *models.EscalationPolicy, sent int, firedAt, now time.Time) ([]models.EscalationStep, int, *time.Time) {
+	var due []models.EscalationStep
+	repeated := false
+	for {
+		at, ok := stepTime(policy, sent, firedAt)
+		if !ok {
+			return due, sent, nil
+		}
+		if at.After(now) {
+			return due, sent, &at
+		}
+
+		if sent < len(policy.Steps) {
+			due = append(due, policy.Steps[sent])
+		} else if !repeated {
+			due = append(due, policy.Steps[len(policy.Steps)-1])
+			repeated = true
+		}
+		sent++
+	}
+}
+
+// stepTime returns when step i of policy is due, counting repeats of the
+// last step as further steps
+func stepTime(policy *models.EscalationPolicy, i int, firedAt time.Time) (time.Time, bool) {
+	n := len(policy.Steps)
+	switch {
+	case i < n:
+		return firedAt.Add(time.Duration(policy.Steps[i].AfterSeconds) * time.Second), true
+	case n == 0 || policy.RepeatSeconds <= 0:
+		return time.Time{}, false
+	}
+	offset := policy.Steps[n-1].AfterSeconds + (i-n+1)*policy.RepeatSeconds
+	return firedAt.Add(time.Duration(offset) * time.Second), true
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+func TestEscalate(t *testing.T) {
+	firedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) *time.Time {
+		t := firedAt.Add(time.Duration(seconds) * time.Second)
+		return &t
+	}
+
+	sse := models.EscalationStep{AfterSeconds: 0, Channel: models.ChannelSSE}
+	email := models.EscalationStep{AfterSeconds: 0, Channel: models.ChannelEmail}
+	webhook := models.EscalationStep{AfterSeconds: 60, Channel: models.ChannelWebhook, Target: "hook"}
+	page := models.EscalationStep{AfterSeconds: 120, Channel: models.ChannelEmail, Target: "oncall@example.com"}
+
+	tests := []struct {
+		name     string
+		policy   models.EscalationPolicy
+		sent     int
+		now      int // seconds after the firing
+		wantDue  []models.EscalationStep
+		wantSent int
+		wantNext *time.Time
+	}{
+		{
+			name:     "steps with equal delays are due together",
+			policy:   models.EscalationPolicy{Steps: []models.EscalationStep{sse, email, webhook}},
+			now:      0,
+			wantDue:  []models.EscalationStep{sse, email},
+			wantSent: 2,
+			wantNext: at(60),
+		},
+		{
+			name:     "nothing is due before the next step",
+			policy:   models.EscalationPolicy{Steps: []models.EscalationStep{sse, webhook}},
+			sent:     1,
+			now:      59,
+			wantSent: 1,
+			wantNext: at(60),
+		},
+		{
+			name:     "missed steps are all sent in one claim",
+			policy:   models.EscalationPolicy{Steps: []models.EscalationStep{sse, webhook, page}},
+			now:      150,
+			wantDue:  []models.EscalationStep{sse, webhook, page},
+			wantSent: 3,
+		},
+		{
+			name:     "missed steps and repeats send the last step once more",
+			policy:   models.EscalationPolicy{Steps: []models.EscalationStep{sse, webhook}, RepeatSeconds: 300},
+			now:      400,
+			wantDue:  []models.EscalationStep{sse, webhook, webhook},
+			wantSent: 3,
+			wantNext: at(660),
+		},
+		{
+			name:   "missed repeats are sent once",
+			policy: models.EscalationPolicy{Steps: []models.EscalationStep{sse, webhook}, RepeatSeconds: 300},
+			sent:   2,
+			// Repeats were due at 360, 660 and 960
+			now:      1000,
+			wantDue:  []models.EscalationStep{webhook},
+			wantSent: 5,
+			wantNext: at(1260),
+		},
+		{
+			name:     "a policy without repeats ends after its last step",
+			policy:   models.EscalationPolicy{Steps: []models.EscalationStep{sse, webhook}},
+			sent:     1,
+			now:      60,
+			wantDue:  []models.EscalationStep{webhook},
+			wantSent: 2,
+		},
+		{
+			name:     "a finished policy without repeats has nothing left",
+			policy:   models.EscalationPolicy{Steps: []models.EscalationStep{sse, webhook}},
+			sent:     2,
+			now:      86400,
+			wantSent: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		due, sent, next := Escalate(&tt.policy, tt.sent, firedAt, *at(tt.now))
+		if !reflect.DeepEqual(due, tt.wantDue) {
+			t.Errorf("%s: due %v, want %v", tt.name, due, tt.wantDue)
+		}
+		if sent != tt.wantSent {
+			t.Errorf("%s: sent %d, want %d", tt.name, sent, tt.wantSent)
+		}
+		switch {
+		case next == nil && tt.wantNext == nil:
+		case next == nil || tt.wantNext == nil || !next.Equal(*tt.wantNext):
+			t.Errorf("%s: next %v, want %v", tt.name, next, tt.wantNext)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"time"
+
+	"pricenotification/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// NewOutboxEntry creates the pending delivery of a firing on channel to
+// target, due at now
+func NewOutboxEntry(triggered *models.TriggeredAlert, channel, target string, now time.Time) *models.OutboxEntry {
+	return &models.OutboxEntry{
+		ID:               uuid.New().String(),
+		TriggeredAlertID: triggered.ID,
+		Channel:          channel,
+		Target:           target,
+		Status:           models.OutboxPending,
+		NextAttemptAt:    now,
+		CreatedAt:        now,
+	}
+}
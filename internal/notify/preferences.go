@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+// DefaultPreferences are used for users who never saved any: every channel,
+// at any time, without a cap
+func DefaultPreferences(userID string) *models.NotificationPreferences {
+	return &models.NotificationPreferences{
+		UserID:   userID,
+		Channels: append([]string(nil), models.Channels...),
+	}
+}
+
+// Route returns the channels a firing on symbol goes out on at now. A
+// symbol override replaces the enabled channels; during quiet hours only
+// in-app (SSE) delivery remains.
+func Route(prefs *models.NotificationPreferences, symbol string, now time.Time) []string {
+	channels := prefs.Channels
+	if override, ok := prefs.SymbolOverrides[symbol]; ok {
+		if override.Muted {
+			return nil
+		}
+		channels = override.Channels
+	}
+
+	if prefs.QuietHours != nil && InQuietHours(prefs.QuietHours, now) {
+		return InAppOnly(channels)
+	}
+	return channels
+}
+
+// InAppOnly drops every channel that reaches the user outside the app
+func InAppOnly(channels []string) []string {
+	for _, channel := range channels {
+		if channel == models.ChannelSSE {
+			return []string{models.ChannelSSE}
+		}
+	}
+	return nil
+}
+
+// InQuietHours reports whether now falls within q in its timezone. Ranges
+// may wrap midnight, e.g. 22:00 to 07:00.
+func InQuietHours(q *models.QuietHours, now time.Time) bool {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, err1 := ParseClock(q.Start)
+	end, err2 := ParseClock(q.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// ParseClock parses an HH:MM time of day into minutes after midnight
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pricenotification/internal/database"
+	"pricenotification/internal/logger"
+	"pricenotification/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Webhook request headers. The signature is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)), so receivers
+// can verify both origin and freshness.
+const (
+	SignatureHeader = "X-Signature-256"
+	TimestampHeader = "X-Webhook-Timestamp"
+	WebhookIDHeader = "X-Webhook-ID"
+	DeliveryHeader  = "X-Delivery-ID"
+)
+
+// ErrCircuitOpen is returned when an endpoint's circuit breaker rejects a delivery
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// WebhookPayload is the JSON body POSTed for every triggered alert
+type WebhookPayload struct {
+	Event string                 `json:"event"`
+	Alert *models.TriggeredAlert `json:"alert"`
+}
+
+// WebhookSender delivers triggered alerts to webhooks with retries,
+// per-endpoint circuit breaking and dead-lettering of exhausted deliveries
+type WebhookSender struct {
+	client      *http.Client
+	maxAttempts int
+	baseBackoff time.Duration
+	breakers    *breakers
+}
+
+// NewWebhookSender creates a sender with production defaults: 5 attempts
+// starting at 1s backoff, and a breaker that opens for a minute after 5
+// consecutive failures
+func NewWebhookSender() *WebhookSender {
+	return &WebhookSender{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 5,
+		baseBackoff: time.Second,
+		breakers:    newBreakers(5, time.Minute),
+	}
+}
+
+// Sign computes the signature header value for a request body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers triggered to hook, retrying with exponential backoff. When
+// every attempt fails the delivery is written to the dead-letter table and
+// the last error is returned.
+func (s *WebhookSender) Send(ctx context.Context, hook *models.Webhook, triggered *models.TriggeredAlert) error {
+	body, err := json.Marshal(WebhookPayload{Event: "alert.triggered", Alert: triggered})
+	if err != nil {
+		return err
+	}
+
+	b := s.breakers.get(hook.ID)
+	backoff := s.baseBackoff
+	attempts := 0
+
+	for attempts < s.maxAttempts {
+		if !b.allow(time.Now()) {
+			err = ErrCircuitOpen
+			break
+		}
+
+		attempts++
+		var retryable bool
+		retryable, err = s.post(ctx, hook, triggered.ID, body)
+		b.record(err == nil, time.Now())
+		if err == nil {
+			logger.Log.Info("Webhook delivered",
+				zap.String("webhook_id", hook.ID),
+				zap.String("triggered_alert_id", triggered.ID),
+				zap.Int("attempts", attempts),
+			)
+			return nil
+		}
+
+		logger.Log.Warn("Webhook delivery attempt failed",
+			zap.String("webhook_id", hook.ID),
+			zap.Int("attempt", attempts),
+			zap.Error(err),
+		)
+		if !retryable || attempts == s.maxAttempts {
+			break
+		}
+
+		// Full jitter keeps many failing deliveries from retrying in lockstep
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			attempts = s.maxAttempts
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+
+	s.deadLetter(hook, triggered, body, attempts, err)
+	return err
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (s *WebhookSender) post(ctx context.Context, hook *models.Webhook, deliveryID string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+	req.Header.Set(WebhookIDHeader, hook.ID)
+	req.Header.Set(DeliveryHeader, deliveryID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("webhook responded %s", resp.Status)
+	// Other client errors mean the request itself is rejected
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retryable, err
+}
+
+// deadLetter records an exhausted delivery
+func (s *WebhookSender) deadLetter(hook *models.Webhook, triggered *models.TriggeredAlert, body []byte, attempts int, lastErr error) {
+	logger.Log.Error("Webhook delivery exhausted, dead-lettering",
+		zap.String("webhook_id", hook.ID),
+		zap.String("triggered_alert_id", triggered.ID),
+		zap.Int("attempts", attempts),
+		zap.Error(lastErr),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	database.CreateWebhookDeadLetter(ctx, &models.WebhookDeadLetter{
+		ID:               uuid.New().String(),
+		WebhookID:        hook.ID,
+		TriggeredAlertID: triggered.ID,
+		Payload:          string(body),
+		Attempts:         attempts,
+		LastError:        lastErr.Error(),
+		CreatedAt:        time.Now().UTC(),
+	})
+}
@@ -0,0 +1,158 @@
+// Package pricewindow keeps the recent price range and volume of each price
+// stream in per-minute buckets, for alerts on moves and volume over a
+// trailing window
+package pricewindow
+
+import (
+	"sync"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+// MaxSpan is the longest look-back a window keeps
+const MaxSpan = 24 * time.Hour
+
+// bucket is the price range, last price and volume of one minute
+type bucket struct {
+	minute           int64 // Unix minute
+	low, high, close float64
+	volume           float64
+}
+
+// Window is the rolling price range of one stream, in minute buckets
+// ordered from oldest to newest
+type Window struct {
+	mu      sync.Mutex
+	buckets []bucket
+}
+
+// Add records a trade of size at price seen at the given time
+func (w *Window) Add(price, size float64, at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.merge(bucket{minute: at.Unix() / 60, low: price, high: price, close: price, volume: size})
+	// A late trade must not hold the span back
+	w.trim(w.buckets[len(w.buckets)-1].minute)
+}
+
+// Seed fills the window from 1m candles, e.g. after a restart
+func (w *Window) Seed(candles []*models.Candle) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, c := range candles {
+		w.merge(bucket{minute: c.OpenTime.Unix() / 60, low: c.Low, high: c.High, close: c.Close, volume: c.Volume})
+	}
+	if n := len(w.buckets); n > 0 {
+		w.trim(w.buckets[n-1].minute)
+	}
+}
+
+// Range returns the lowest and highest price since the given time, at
+// minute resolution. ok is false when the window holds no price since then.
+func (w *Window) Range(since time.Time) (low, high float64, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	from := since.Unix() / 60
+	for i := len(w.buckets) - 1; i >= 0 && w.buckets[i].minute >= from; i-- {
+		b := w.buckets[i]
+		if !ok {
+			low, high, ok = b.low, b.high, true
+			continue
+		}
+		low, high = min(low, b.low), max(high, b.high)
+	}
+	return low, high, ok
+}
+
+// CloseAt returns the last price recorded at or before the minute of t
+func (w *Window) CloseAt(t time.Time) (float64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	minute := t.Unix() / 60
+	for i := len(w.buckets) - 1; i >= 0; i-- {
+		if w.buckets[i].minute <= minute {
+			return w.buckets[i].close, true
+		}
+	}
+	return 0, false
+}
+
+// Volume returns the volume traded from the minute of from up to, but not
+// including, the minute of to
+func (w *Window) Volume(from, to time.Time) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	first, end := from.Unix()/60, to.Unix()/60
+	volume := 0.0
+	for i := len(w.buckets) - 1; i >= 0 && w.buckets[i].minute >= first; i-- {
+		if w.buckets[i].minute < end {
+			volume += w.buckets[i].volume
+		}
+	}
+	return volume
+}
+
+// merge folds b into the bucket of its minute, keeping the order
+func (w *Window) merge(b bucket) {
+	i := len(w.buckets)
+	for i > 0 && w.buckets[i-1].minute > b.minute {
+		i--
+	}
+	if i > 0 && w.buckets[i-1].minute == b.minute {
+		prev := &w.buckets[i-1]
+		prev.low, prev.high, prev.close = min(prev.low, b.low), max(prev.high, b.high), b.close
+		prev.volume += b.volume
+		return
+	}
+	w.buckets = append(w.buckets, bucket{})
+	copy(w.buckets[i+1:], w.buckets[i:])
+	w.buckets[i] = b
+}
+
+// trim drops the buckets older than MaxSpan before minute
+func (w *Window) trim(minute int64) {
+	oldest := minute - int64(MaxSpan/time.Minute)
+	i := 0
+	for i < len(w.buckets) && w.buckets[i].minute < oldest {
+		i++
+	}
+	w.buckets = w.buckets[i:]
+}
+
+// streamKey identifies one price stream
+type streamKey struct {
+	symbol   string
+	exchange string
+}
+
+// Tracker holds the windows of every stream. It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	windows map[streamKey]*Window
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{windows: make(map[streamKey]*Window)}
+}
+
+// Get returns the window of a stream, creating it when needed. created
+// reports a new window, which the caller may want to seed.
+func (t *Tracker) Get(symbol, exchange string) (w *Window, created bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := streamKey{symbol, exchange}
+	if w, ok := t.windows[key]; ok {
+		return w, false
+	}
+	w = &Window{}
+	t.windows[key] = w
+	return w, true
+}
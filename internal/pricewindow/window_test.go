@@ -0,0 +1,145 @@
+package pricewindow
+
+import (
+	"testing"
+	"time"
+
+	"pricenotification/internal/models"
+)
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// at is start plus minutes and seconds
+func at(minutes, seconds int) time.Time {
+	return start.Add(time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second)
+}
+
+func TestRange(t *testing.T) {
+	var w Window
+	w.Add(100, 1, at(0, 10))
+	w.Add(104, 1, at(0, 50))
+	w.Add(98, 1, at(2, 0))
+	// Out of order: lands in the minute before the last one
+	w.Add(110, 1, at(1, 30))
+	w.Add(99, 1, at(0, 20))
+
+	tests := []struct {
+		since     time.Time
+		low, high float64
+		ok        bool
+	}{
+		{at(0, 0), 98, 110, true},
+		// Minute resolution: any time in a minute includes all of it
+		{at(0, 59), 98, 110, true},
+		{at(1, 0), 98, 110, true},
+		{at(2, 0), 98, 98, true},
+		{at(3, 0), 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		low, high, ok := w.Range(tt.since)
+		if low != tt.low || high != tt.high || ok != tt.ok {
+			t.Errorf("Range(%s) = %v, %v, %v; want %v, %v, %v",
+				tt.since.Format("15:04:05"), low, high, ok, tt.low, tt.high, tt.ok)
+		}
+	}
+	if len(w.buckets) != 3 {
+		t.Errorf("%d buckets, want one per minute", len(w.buckets))
+	}
+}
+
+func TestCloseAt(t *testing.T) {
+	var w Window
+	if _, ok := w.CloseAt(at(0, 0)); ok {
+		t.Error("CloseAt on an empty window succeeded")
+	}
+
+	w.Add(100, 1, at(0, 10))
+	w.Add(101, 1, at(0, 40))
+	w.Add(105, 1, at(3, 0))
+
+	tests := []struct {
+		t    time.Time
+		want float64
+		ok   bool
+	}{
+		{at(-1, 0), 0, false},
+		{at(0, 0), 101, true},
+		// Minutes without trades carry the last close
+		{at(2, 0), 101, true},
+		{at(3, 30), 105, true},
+		{at(10, 0), 105, true},
+	}
+	for _, tt := range tests {
+		if got, ok := w.CloseAt(tt.t); got != tt.want || ok != tt.ok {
+			t.Errorf("CloseAt(%s) = %v, %v; want %v, %v", tt.t.Format("15:04:05"), got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	var w Window
+	w.Add(100, 1, at(0, 0))
+	w.Add(101, 1, start.Add(MaxSpan))
+	if low, _, _ := w.Range(at(0, 0)); low != 100 {
+		t.Errorf("bucket exactly MaxSpan old was trimmed")
+	}
+
+	w.Add(102, 1, start.Add(MaxSpan+time.Minute))
+	if low, _, _ := w.Range(at(0, 0)); low != 101 {
+		t.Errorf("Range after MaxSpan = low %v, want the oldest bucket trimmed", low)
+	}
+
+	// A late trade older than the span is not kept either
+	w.Add(50, 1, at(-5, 0))
+	if low, _, _ := w.Range(at(-10, 0)); low != 101 {
+		t.Errorf("Range after a late trade = low %v, want it trimmed", low)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	var w Window
+	w.Seed([]*models.Candle{
+		{OpenTime: at(0, 0), Low: 95, High: 105, Close: 100, Volume: 2},
+		{OpenTime: at(1, 0), Low: 99, High: 101, Close: 101, Volume: 3},
+	})
+	// Live trades merge into the seeded minutes
+	w.Add(110, 1, at(1, 30))
+
+	if low, high, _ := w.Range(at(0, 0)); low != 95 || high != 110 {
+		t.Errorf("Range = %v-%v, want 95-110", low, high)
+	}
+	if got, _ := w.CloseAt(at(1, 0)); got != 110 {
+		t.Errorf("CloseAt = %v, want the live trade 110", got)
+	}
+}
+
+func TestVolume(t *testing.T) {
+	var w Window
+	w.Add(100, 1, at(0, 10))
+	w.Add(100, 2, at(0, 50))
+	w.Add(100, 4, at(2, 0))
+	w.Add(100, 8, at(3, 59))
+	// Out of order: merged into its own minute
+	w.Add(100, 16, at(1, 0))
+	w.Add(100, 32, at(0, 30))
+
+	tests := []struct {
+		from, to time.Time
+		want     float64
+	}{
+		{at(0, 0), at(4, 0), 63},
+		// The minute of to is left out, the minute of from is included
+		{at(0, 59), at(3, 59), 55},
+		{at(1, 0), at(2, 0), 16},
+		{at(2, 0), at(2, 0), 0},
+		{at(3, 0), at(10, 0), 8},
+		{at(-5, 0), at(0, 0), 0},
+	}
+	for _, tt := range tests {
+		if got := w.Volume(tt.from, tt.to); got != tt.want {
+			t.Errorf("Volume(%s, %s) = %v, want %v",
+				tt.from.Format("15:04:05"), tt.to.Format("15:04:05"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS alerts (
+    id              TEXT PRIMARY KEY,
+    user_id         TEXT NOT NULL,
+    symbol          TEXT NOT NULL,
+    upper_threshold DOUBLE PRECISION,
+    lower_threshold DOUBLE PRECISION,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_user_id ON alerts (user_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_symbol ON alerts (symbol);
@@ -0,0 +1,3 @@
+-- Which price stream an alert watches: a venue name (coinbase, kraken, ...)
+-- or 'consolidated' for the cross-venue price. NULL is read as consolidated.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS exchange TEXT;
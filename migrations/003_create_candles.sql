@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS candles (
+    symbol      TEXT NOT NULL,
+    exchange    TEXT NOT NULL,
+    timeframe   TEXT NOT NULL,
+    open_time   TIMESTAMPTZ NOT NULL,
+    open        DOUBLE PRECISION NOT NULL,
+    high        DOUBLE PRECISION NOT NULL,
+    low         DOUBLE PRECISION NOT NULL,
+    close       DOUBLE PRECISION NOT NULL,
+    volume      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    trade_count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (symbol, exchange, timeframe, open_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_candles_timeframe_open_time ON candles (timeframe, open_time);
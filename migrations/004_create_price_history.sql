@@ -0,0 +1,12 @@
+-- Raw trades and consolidated prices. Rows older than the history service's
+-- raw retention are deleted; older queries are answered from candles.
+CREATE TABLE IF NOT EXISTS price_history (
+    symbol    TEXT NOT NULL,
+    exchange  TEXT NOT NULL,
+    price     DOUBLE PRECISION NOT NULL,
+    size      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    traded_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_history_series_time ON price_history (symbol, exchange, traded_at DESC);
+CREATE INDEX IF NOT EXISTS idx_price_history_traded_at ON price_history USING BRIN (traded_at);
@@ -0,0 +1,17 @@
+-- One row per alert firing. No foreign key to alerts: history is kept after
+-- an alert is deleted.
+CREATE TABLE IF NOT EXISTS triggered_alerts (
+    id              TEXT PRIMARY KEY,
+    alert_id        TEXT NOT NULL,
+    user_id         TEXT NOT NULL,
+    symbol          TEXT NOT NULL,
+    exchange        TEXT NOT NULL,
+    price           DOUBLE PRECISION NOT NULL,
+    threshold       DOUBLE PRECISION NOT NULL,
+    direction       TEXT NOT NULL,
+    triggered_at    TIMESTAMPTZ NOT NULL,
+    delivery_status TEXT NOT NULL DEFAULT 'pending'
+);
+
+CREATE INDEX IF NOT EXISTS idx_triggered_alerts_alert_id ON triggered_alerts (alert_id, triggered_at DESC);
+CREATE INDEX IF NOT EXISTS idx_triggered_alerts_user_id ON triggered_alerts (user_id, triggered_at DESC);
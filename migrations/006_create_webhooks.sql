@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    url        TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+
+-- Deliveries that exhausted their retries, kept for inspection and replay
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+    id                 TEXT PRIMARY KEY,
+    webhook_id         TEXT NOT NULL,
+    triggered_alert_id TEXT NOT NULL,
+    payload            TEXT NOT NULL,
+    attempts           INTEGER NOT NULL,
+    last_error         TEXT NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_webhook_id ON webhook_dead_letters (webhook_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- Per-channel addresses of a user, e.g. ('alice', 'email', 'alice@example.com')
+CREATE TABLE IF NOT EXISTS user_contacts (
+    user_id    TEXT NOT NULL,
+    channel    TEXT NOT NULL,
+    address    TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, channel)
+);
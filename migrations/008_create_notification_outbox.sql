@@ -0,0 +1,28 @@
+-- Deliveries of triggered alerts, written in the same transaction as the
+-- triggered_alerts row and consumed by the dispatcher (at-least-once)
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id                 TEXT PRIMARY KEY,
+    triggered_alert_id TEXT NOT NULL REFERENCES triggered_alerts (id),
+    channel            TEXT NOT NULL,
+    target             TEXT NOT NULL DEFAULT '',
+    status             TEXT NOT NULL DEFAULT 'pending',
+    attempts           INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error         TEXT NOT NULL DEFAULT '',
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_due ON notification_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_triggered ON notification_outbox (triggered_alert_id);
+
+-- Audit trail: one row per delivery attempt
+CREATE TABLE IF NOT EXISTS delivery_attempts (
+    id           BIGSERIAL PRIMARY KEY,
+    outbox_id    TEXT NOT NULL REFERENCES notification_outbox (id),
+    attempt      INTEGER NOT NULL,
+    success      BOOLEAN NOT NULL,
+    error        TEXT NOT NULL DEFAULT '',
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_attempts_outbox_id ON delivery_attempts (outbox_id);
@@ -0,0 +1,12 @@
+-- How and when each user wants to be notified. Addresses live in
+-- user_contacts; a user without a row gets every channel at any time.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id          TEXT PRIMARY KEY,
+    channels         TEXT[] NOT NULL DEFAULT '{sse,webhook,email}',
+    symbol_overrides JSONB NOT NULL DEFAULT '{}',
+    quiet_start      TEXT,
+    quiet_end        TEXT,
+    timezone         TEXT,
+    max_per_hour     INTEGER NOT NULL DEFAULT 0,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
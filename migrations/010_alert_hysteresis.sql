@@ -0,0 +1,4 @@
+-- Band around a threshold that price must leave before the alert re-arms:
+-- an upper threshold re-arms below upper - hysteresis, a lower threshold
+-- above lower + hysteresis
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS hysteresis DOUBLE PRECISION NOT NULL DEFAULT 0;
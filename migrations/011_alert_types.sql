@@ -0,0 +1,6 @@
+-- Percentage-change alert kinds next to absolute thresholds
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'threshold';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS change_pct DOUBLE PRECISION;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS window_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS direction TEXT NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS reference_price DOUBLE PRECISION;
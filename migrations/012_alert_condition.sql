@@ -0,0 +1,2 @@
+-- Expression of condition alerts, e.g. 'price > 70000 && change_1h_pct > 3'
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS condition TEXT NOT NULL DEFAULT '';
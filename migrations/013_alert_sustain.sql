@@ -0,0 +1,4 @@
+-- Sustained conditions: an alert fires only once its condition has held for
+-- sustain_seconds and/or sustain_updates consecutive price updates
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS sustain_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS sustain_updates INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Trailing alerts retrace change_pct percent or trail_amount from the
+-- extreme price since creation; reference_price holds the starting extreme
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS trail_amount DOUBLE PRECISION;
@@ -0,0 +1,6 @@
+-- Spread alerts compare a value derived from two legs, symbol on exchange
+-- and leg_symbol on leg_exchange, with their thresholds
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS formula TEXT NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS leg_symbol TEXT NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS leg_exchange TEXT NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS stale_seconds INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Indicator alerts evaluate their condition over candles of candle_interval,
+-- per trade or, with on_close, once per closed candle
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS candle_interval TEXT NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS on_close BOOLEAN NOT NULL DEFAULT FALSE;
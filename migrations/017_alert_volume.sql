@@ -0,0 +1,10 @@
+-- Volume spike alerts compare the volume of window_minutes with its average
+-- over the preceding baseline_minutes; large_trade alerts fire on single
+-- trades of at least min_size, optionally of one taker side only
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS multiplier DOUBLE PRECISION;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS baseline_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS min_size DOUBLE PRECISION;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS trade_side TEXT NOT NULL DEFAULT '';
+
+-- Trade size of large_trade firings and window volume of volume spikes
+ALTER TABLE triggered_alerts ADD COLUMN IF NOT EXISTS size DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+-- Alert lifecycle: recurring alerts fire after every cooldown (seconds, 0
+-- for the service default), once and times alerts until trigger_count
+-- reaches 1 or max_triggers, which moves them to triggered. Active alerts
+-- past expires_at are moved to expired. Only active alerts are evaluated.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'recurring';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS cooldown INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS max_triggers INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS trigger_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_alerts_expires_at ON alerts (expires_at) WHERE status = 'active' AND expires_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- A snoozed alert is paused until snoozed_until, when price processing
+-- resumes it
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_alerts_snoozed_until ON alerts (snoozed_until) WHERE status = 'paused' AND snoozed_until IS NOT NULL;
@@ -0,0 +1,26 @@
+-- Escalation policies re-notify each firing of an alert on a schedule until
+-- it is acknowledged. steps is a JSON array of {after_seconds, channel,
+-- target}; past the last step, it repeats every repeat_seconds (0 stops).
+CREATE TABLE IF NOT EXISTS escalation_policies (
+    id             TEXT PRIMARY KEY,
+    user_id        TEXT NOT NULL,
+    name           TEXT NOT NULL,
+    steps          JSONB NOT NULL,
+    repeat_seconds INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_escalation_policies_user_id ON escalation_policies (user_id);
+
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS escalation_id TEXT NOT NULL DEFAULT '';
+
+-- Escalation state of a firing: the steps sent so far and when the next one
+-- is due, NULL once acknowledged or when no step is left
+ALTER TABLE triggered_alerts ADD COLUMN IF NOT EXISTS escalation_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE triggered_alerts ADD COLUMN IF NOT EXISTS escalation_step INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE triggered_alerts ADD COLUMN IF NOT EXISTS next_escalation_at TIMESTAMPTZ;
+ALTER TABLE triggered_alerts ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMPTZ;
+ALTER TABLE triggered_alerts ADD COLUMN IF NOT EXISTS acknowledged_by TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_triggered_alerts_next_escalation ON triggered_alerts (next_escalation_at) WHERE next_escalation_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_triggered_alerts_unacknowledged ON triggered_alerts (alert_id) WHERE escalation_id <> '' AND acknowledged_at IS NULL;